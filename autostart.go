@@ -0,0 +1,358 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// systemdUnitPath directory of the generated systemd units
+var systemdUnitPath = "/etc/systemd/system"
+
+// errAutostartUnsupported the init system of the machine is not supported by enable/disable
+var errAutostartUnsupported = errors.New("boot-time start is not supported by the init system of this machine")
+
+var systemdUnit = template.Must(template.New("systemd").Parse(`[Unit]
+Description={{.Name}}
+After=network.target
+
+[Service]
+Type=forking
+PIDFile={{.PidFile}}
+ExecStart={{.Executable}} {{.Start}}
+ExecStop={{.Executable}} {{.Stop}}
+{{- with .Restart}}
+Restart={{.}}
+{{- end}}
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+// service everything an init system needs to know to run the worker
+type service struct {
+	Name        string
+	PidFile     string
+	Executable  string
+	Command     []string // the start command, such as "http start" for `myapp http start`
+	Arguments   []string // given to the start command, see startArguments
+	StopArgs    []string // the stop command
+	RestartArgs []string // the restart command
+	StatusArgs  []string // the status command
+	Restart     string   // systemd restart policy, empty for none
+}
+
+// StartArgs the start command with its arguments
+func (svc *service) StartArgs() []string {
+	return append(append([]string(nil), svc.Command...), svc.Arguments...)
+}
+
+// Start the arguments of the start command, quoted for a systemd command line
+func (svc *service) Start() string {
+	return unitCommandLine(svc.StartArgs())
+}
+
+// Stop the arguments of the stop command, quoted for a systemd command line
+func (svc *service) Stop() string {
+	return unitCommandLine(svc.StopArgs)
+}
+
+// integration how the service files of an init system are written and driven
+type integration interface {
+	// filename the path of the service file of the service called name
+	filename(name string) string
+	// render the service file of svc
+	render(svc *service) (string, error)
+	// load let the init system read the service file written for svc
+	load(svc *service) error
+	// enable register the service called name for boot-time start, now also starts it
+	enable(name string, now bool) error
+	// disable deregister the service called name from boot-time start, now also stops it
+	disable(name string, now bool) error
+	// unload let the init system forget the removed service file of the service called name
+	unload(name string) error
+	// hint how to start the installed service called name
+	hint(name string) string
+}
+
+// integrations the init systems install, enable and disable support
+var integrations = map[Init]integration{
+	InitSystemd: systemd{},
+	InitLaunchd: launchd{},
+	InitOpenRC:  openrc{initScript{openrcScript}},
+	InitSysV:    sysv{initScript{sysvScript}},
+}
+
+// integrationOf the integration of the init system called name, the one of the machine if empty
+func integrationOf(name string) (integration, error) {
+	if name == "" {
+		name = string(InitSystem())
+	}
+	if integration, ok := integrations[Init(name)]; ok {
+		return integration, nil
+	}
+	return nil, errAutostartUnsupported
+}
+
+// systemd the integration of systemd units
+type systemd struct{}
+
+func (systemd) filename(name string) string {
+	return filepath.Join(systemdUnitPath, name+".service")
+}
+
+func (systemd) render(svc *service) (string, error) {
+	var unit strings.Builder
+	err := systemdUnit.Execute(&unit, svc)
+	return unit.String(), err
+}
+
+func (systemd) load(svc *service) error {
+	return systemctl("daemon-reload")
+}
+
+func (systemd) enable(name string, now bool) error {
+	args := []string{"enable", name}
+	if now {
+		args = append(args, "--now")
+	}
+	return systemctl(args...)
+}
+
+func (systemd) disable(name string, now bool) error {
+	args := []string{"disable", name}
+	if now {
+		args = append(args, "--now")
+	}
+	return systemctl(args...)
+}
+
+func (systemd) unload(name string) error {
+	return systemctl("daemon-reload")
+}
+
+func (systemd) hint(name string) string {
+	return fmt.Sprintf("run `systemctl enable --now %s` to start it", name)
+}
+
+// newService describe the worker whose lifecycle commands are siblings of cmd
+func newService(worker *Process, cmd *cobra.Command) (*service, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	// the command path without the binary name, such as "http" for `myapp http enable`
+	path := strings.Fields(strings.TrimPrefix(cmd.Parent().CommandPath(), cmd.Root().Name()))
+	command := func(verb string) []string {
+		return append(append([]string(nil), path...), worker.verbName(verb))
+	}
+	return &service{
+		Name:        worker.worker.Name(),
+		PidFile:     worker.pid.SaveFilename(),
+		Executable:  executable,
+		Command:     command(StartCommand),
+		StopArgs:    command(StopCommand),
+		RestartArgs: command(RestartCommand),
+		StatusArgs:  command(StatusCommand),
+	}, nil
+}
+
+// writeService write the service file of svc and let the init system load it
+func writeService(system integration, svc *service) error {
+	body, err := system.render(svc)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(system.filename(svc.Name), []byte(body), 0644); err != nil {
+		return err
+	}
+	return system.load(svc)
+}
+
+// systemctl run systemctl with the terminal attached
+func systemctl(args ...string) error {
+	cmd := exec.Command("systemctl", args...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// enableAutostart register the worker for boot-time start, installing it first when it is not, now also starts it
+func enableAutostart(worker *Process, cmd *cobra.Command, now bool) error {
+	system, err := integrationOf("")
+	if err != nil {
+		return err
+	}
+	svc, err := newService(worker, cmd)
+	if err != nil {
+		return err
+	}
+
+	// an installed service keeps the arguments and the restart policy given to install
+	if !exists(system.filename(svc.Name)) {
+		if err = writeService(system, svc); err != nil {
+			return err
+		}
+	}
+	return system.enable(svc.Name, now)
+}
+
+// disableAutostart deregister the worker from boot-time start, now also stops it
+func disableAutostart(worker *Process, now bool) error {
+	system, err := integrationOf("")
+	if err != nil {
+		return err
+	}
+	return system.disable(worker.worker.Name(), now)
+}
+
+func enable(worker *Process) *cobra.Command {
+	enable := &cobra.Command{
+		Use:   "enable",
+		Short: fmt.Sprintf("start %s at boot", worker.worker.Name()),
+		RunE: runE(func(cmd *cobra.Command, args []string) error {
+			now, _ := cmd.Flags().GetBool("now")
+			if err := enableAutostart(worker, cmd, now); err != nil {
+				return failed(worker.result(EnableCommand, ""), err, 1)
+			}
+			return nil
+		}),
+	}
+	enable.Flags().Bool("now", false, "also start it now")
+	return enable
+}
+
+func disable(worker *Process) *cobra.Command {
+	disable := &cobra.Command{
+		Use:   "disable",
+		Short: fmt.Sprintf("do not start %s at boot", worker.worker.Name()),
+		RunE: runE(func(cmd *cobra.Command, args []string) error {
+			now, _ := cmd.Flags().GetBool("now")
+			if err := disableAutostart(worker, now); err != nil {
+				return failed(worker.result(DisableCommand, ""), err, 1)
+			}
+			return nil
+		}),
+	}
+	disable.Flags().Bool("now", false, "also stop it now")
+	return disable
+}
+
+// startArguments the flags changed on the command line of cmd, except its own, and the arguments after "--",
+// for the start command of the service
+func startArguments(cmd *cobra.Command, args []string) []string {
+	var arguments []string
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if cmd.LocalNonPersistentFlags().Lookup(flag.Name) == nil {
+			arguments = append(arguments, fmt.Sprintf("--%s=%s", flag.Name, flag.Value.String()))
+		}
+	})
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		arguments = append(arguments, "--")
+		arguments = append(arguments, args[dash:]...)
+	}
+	return arguments
+}
+
+// unitCommandLine join args to a systemd command line
+func unitCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = unitQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// unitQuote quote arg for a systemd command line when needed
+func unitQuote(arg string) string {
+	if arg == "" || strings.ContainsAny(arg, " \t\n\"'\\;$%") {
+		return strconv.Quote(strings.Replace(strings.Replace(arg, "%", "%%", -1), "$", "$$", -1))
+	}
+	return arg
+}
+
+func install(worker *Process) *cobra.Command {
+	install := &cobra.Command{
+		Use:   "install [-- args]",
+		Short: fmt.Sprintf("install %s as a service of the init system", worker.worker.Name()),
+		Long: "write the systemd unit, launchd plist or init script that runs the service, the flags of the worker and the arguments after -- " +
+			"given to install are passed to the start command of the service",
+		RunE: runE(func(cmd *cobra.Command, args []string) error {
+			name, _ := cmd.Flags().GetString("init")
+			system, err := integrationOf(name)
+			if err != nil {
+				return failed(worker.result(InstallCommand, ""), err, 1)
+			}
+			svc, err := newService(worker, cmd)
+			if err != nil {
+				return failed(worker.result(InstallCommand, ""), err, 1)
+			}
+			svc.Arguments = startArguments(cmd, args)
+			svc.Restart, _ = cmd.Flags().GetString("restart")
+
+			if stdout, _ := cmd.Flags().GetBool("stdout"); stdout {
+				body, err := system.render(svc)
+				if err != nil {
+					return failed(worker.result(InstallCommand, ""), err, 1)
+				}
+				result := worker.result(InstallCommand, "")
+				result.Message = body
+				report(cmd, result, "%s", body)
+				return nil
+			}
+			if err = writeService(system, svc); err != nil {
+				return failed(worker.result(InstallCommand, ""), err, 1)
+			}
+			result := worker.result(InstallCommand, StateInstalled)
+			result.Message = system.filename(svc.Name)
+			report(cmd, result, "%s installed, %s\n", system.filename(svc.Name), system.hint(svc.Name))
+			return nil
+		}),
+	}
+	install.Flags().String("restart", "on-failure", "restart policy of the service: no, on-failure, always...")
+	install.Flags().String("init", "", "init system to write the service for: systemd, launchd, openrc or sysv, the one of this machine by default")
+	install.Flags().Bool("stdout", false, "print the service file instead of installing it")
+	return install
+}
+
+func uninstall(worker *Process) *cobra.Command {
+	uninstall := &cobra.Command{
+		Use:   "uninstall",
+		Short: fmt.Sprintf("stop %s and remove its service from the init system", worker.worker.Name()),
+		RunE: runE(func(cmd *cobra.Command, args []string) error {
+			name := worker.worker.Name()
+			system, err := integrationOf("")
+			if err != nil {
+				return failed(worker.result(UninstallCommand, ""), err, 1)
+			}
+			if _, err := os.Stat(system.filename(name)); os.IsNotExist(err) {
+				report(cmd, worker.result(UninstallCommand, StateNotInstalled), "%s is not installed\n", name)
+				return nil
+			}
+			if !worker.confirm(cmd, "uninstall") {
+				return exitWith(1)
+			}
+			if err = system.disable(name, true); err == nil {
+				if err = os.Remove(system.filename(name)); err == nil {
+					err = system.unload(name)
+				}
+			}
+			if err != nil {
+				return failed(worker.result(UninstallCommand, ""), err, 1)
+			}
+			report(cmd, worker.result(UninstallCommand, StateUninstalled), "%s uninstalled\n", name)
+			return nil
+		}),
+	}
+
+	addConfirmFlag(uninstall)
+	return uninstall
+}
@@ -0,0 +1,82 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// fakeInit an init system writing the service files to dir and recording the services it enables
+type fakeInit struct {
+	dir     string
+	enabled *[]string
+}
+
+func (system fakeInit) filename(name string) string {
+	return filepath.Join(system.dir, name+".service")
+}
+func (fakeInit) render(svc *service) (string, error) { return systemd{}.render(svc) }
+func (fakeInit) load(*service) error                 { return nil }
+func (fakeInit) disable(string, bool) error          { return nil }
+func (fakeInit) unload(string) error                 { return nil }
+func (fakeInit) hint(string) string                  { return "" }
+func (system fakeInit) enable(name string, now bool) error {
+	*system.enabled = append(*system.enabled, name)
+	return nil
+}
+
+func TestEnableAutostart(t *testing.T) {
+	tests := []struct {
+		name    string
+		install []string // arguments of install, not installed when nil
+		unit    []string // lines the unit has once enabled
+	}{
+		{"installed", []string{"--restart=always", "--", "--port", "80"}, []string{"ExecStart=", "start -- --port 80\n", "Restart=always\n"}},
+		{"not installed", nil, []string{"ExecStart=", "start\n"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "autostart")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			var enabled []string
+			previous := integrations[InitSystem()]
+			integrations[InitSystem()] = fakeInit{dir: dir, enabled: &enabled}
+			defer func() { integrations[InitSystem()] = previous }()
+
+			root := &Daemon{command: &cobra.Command{Use: "app"}}
+			root.AddWorker(NewProcess(testWorker{dir: dir, name: "svc"}))
+			root.command.SetOutput(ioutil.Discard)
+			if test.install != nil {
+				root.command.SetArgs(append([]string{"svc", "install"}, test.install...))
+				if err = root.command.Execute(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			root.command.SetArgs([]string{"svc", "enable"})
+			if err = root.command.Execute(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(enabled, []string{"svc"}) {
+				t.Errorf("enabled %v, want [svc]", enabled)
+			}
+			unit, err := ioutil.ReadFile(filepath.Join(dir, "svc.service"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, line := range test.unit {
+				if !strings.Contains(string(unit), line) {
+					t.Errorf("the unit has no %q:\n%s", line, unit)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// bridgeTimeout how long forwarding a message to another worker may take
+const bridgeTimeout = time.Second
+
+// bridged the bus sockets served by this process, messages are not forwarded to them
+var bridged sync.Map
+
+// bridgeMessage a Message on the bus sockets
+type bridgeMessage struct {
+	Topic   string          `json:"topic"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// BridgeBus exchange the messages of the Bus with the other workers of the binary that call it, when they run in
+// separate children: the child listens on <name>.bus next to its pid file and forwards what is published on its bus
+// to the running ones, so that a config watcher can tell the HTTP worker to reload. the payloads cross as JSON, the
+// other workers receive a json.RawMessage. workers hosted in the same process share the bus without it
+func (process *Process) BridgeBus() *Process {
+	return process.configure("BridgeBus", func() {
+		process.bridgeEnabled = true
+		process.addArtifact(process.busSocket())
+	})
+}
+
+// busSocket the path of the bus socket
+func (process *Process) busSocket() string {
+	return filepath.Join(filepath.Dir(process.pid.SaveFilename()), process.pid.ServicesName+".bus")
+}
+
+// serveBus in the child, deliver the messages of the other workers and forward the ones published here
+func (process *Process) serveBus() error {
+	if !process.bridgeEnabled {
+		return nil
+	}
+	socket := process.busSocket()
+	_ = os.Remove(socket)
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(socket, 0600); err != nil {
+		_ = listener.Close()
+		return err
+	}
+	process.busListener = listener
+	bridged.Store(socket, true)
+	process.Bus().setForward(process.forward)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go process.receive(conn)
+		}
+	}()
+	return nil
+}
+
+// closeBus stop receiving the messages of the other workers and remove the socket
+func (process *Process) closeBus() {
+	if process.busListener != nil {
+		_ = process.busListener.Close()
+		bridged.Delete(process.busSocket())
+		_ = process.pid.remove(process.busSocket())
+	}
+}
+
+// receive deliver the messages of one connection to the subscribers of the bus
+func (process *Process) receive(conn net.Conn) {
+	defer conn.Close()
+	if err := process.AuthorizePeer(conn); err != nil {
+		process.error("bus message refused", "err", err)
+		return
+	}
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var message bridgeMessage
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			process.error("invalid bus message", "err", err)
+			continue
+		}
+		process.Bus().deliver(Message{Topic: message.Topic, Payload: message.Payload})
+	}
+}
+
+// peerSockets the bus sockets of the other bridged workers of the binary, and of their instances
+func (process *Process) peerSockets() []string {
+	var sockets []string
+	for _, node := range command.nodes() {
+		peer := node.worker
+		if !peer.bridgeEnabled {
+			continue
+		}
+		dir := filepath.Dir(peer.pid.SaveFilename())
+		instances, _ := filepath.Glob(filepath.Join(dir, peer.worker.Name()+"-[0-9]*.bus"))
+		for _, socket := range append([]string{filepath.Join(dir, peer.worker.Name()+".bus")}, instances...) {
+			if _, local := bridged.Load(socket); !local {
+				sockets = append(sockets, socket)
+			}
+		}
+	}
+	return sockets
+}
+
+// forward send a message published here to the other bridged workers that are running
+func (process *Process) forward(message Message) {
+	payload, err := json.Marshal(message.Payload)
+	if err != nil {
+		process.error("bus message not forwarded", "topic", message.Topic, "err", err)
+		return
+	}
+	line, _ := json.Marshal(bridgeMessage{Topic: message.Topic, Payload: payload})
+	for _, socket := range process.peerSockets() {
+		conn, err := net.DialTimeout("unix", socket, bridgeTimeout)
+		if err != nil {
+			// not running
+			continue
+		}
+		_ = conn.SetDeadline(time.Now().Add(bridgeTimeout))
+		if _, err = fmt.Fprintf(conn, "%s\n", line); err != nil {
+			process.error("bus message not forwarded", "topic", message.Topic, "socket", socket, "err", err)
+		}
+		_ = conn.Close()
+	}
+}
@@ -0,0 +1,89 @@
+//go:build !windows
+// +build !windows
+
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBridgeReceive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bridge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		lines    []string
+		topics   []string
+		payloads []string
+	}{
+		{"message", []string{`{"topic":"config","payload":{"reload":true}}`}, []string{"config"}, []string{`{"reload":true}`}},
+		{"several", []string{`{"topic":"a","payload":1}`, `{"topic":"b","payload":"x"}`}, []string{"a", "b"}, []string{`1`, `"x"`}},
+		{"invalid line skipped", []string{`not json`, `{"topic":"a","payload":null}`}, []string{"a"}, []string{`null`}},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			process := NewProcess(testWorker{dir: dir, name: fmt.Sprintf("bridge%d", i)}).SetBus(NewBus()).BridgeBus()
+			process.SetLogger(discardLogger{})
+			if err := process.serveBus(); err != nil {
+				t.Fatal(err)
+			}
+			defer process.closeBus()
+			messages, unsubscribe := process.Bus().Subscribe(AllTopics, len(test.lines))
+			defer unsubscribe()
+
+			conn, err := net.Dial("unix", process.busSocket())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, line := range test.lines {
+				fmt.Fprintln(conn, line)
+			}
+			_ = conn.Close()
+
+			for j, topic := range test.topics {
+				select {
+				case message := <-messages:
+					payload, _ := message.Payload.(json.RawMessage)
+					if message.Topic != topic || string(payload) != test.payloads[j] {
+						t.Errorf("received %s %s, want %s %s", message.Topic, payload, topic, test.payloads[j])
+					}
+				case <-time.After(time.Second):
+					t.Fatalf("%s not received", topic)
+				}
+			}
+		})
+	}
+}
+
+func TestBridgeServe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bridge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	process := NewProcess(testWorker{dir: dir, name: "forward"}).SetBus(NewBus()).BridgeBus()
+	if err := process.serveBus(); err != nil {
+		t.Fatal(err)
+	}
+	if process.Bus().forward == nil {
+		t.Error("the messages published on the bus are not forwarded")
+	}
+	if _, local := bridged.Load(process.busSocket()); !local {
+		t.Error("the socket of this process is not marked as local, messages would be sent back to it")
+	}
+	process.closeBus()
+	if _, err := os.Stat(process.busSocket()); !os.IsNotExist(err) {
+		t.Errorf("the socket is left after closeBus: %v", err)
+	}
+}
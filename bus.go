@@ -0,0 +1,104 @@
+package daemon
+
+import "sync"
+
+// AllTopics subscribe to every topic of a Bus
+const AllTopics = "*"
+
+// Message an event exchanged between workers hosted in the same process
+type Message struct {
+	Topic   string
+	Payload interface{}
+}
+
+// Bus a lightweight in-process pub/sub bus, so co-hosted workers can exchange events
+// (config changed, drain requested) without each inventing its own channel wiring
+type Bus struct {
+	mutex       sync.RWMutex
+	subscribers map[string]map[chan Message]struct{}
+	forward     func(Message) // hands the published messages to the other processes, see BridgeBus
+}
+
+// DefaultBus the bus shared by every Process unless SetBus is used
+var DefaultBus = NewBus()
+
+// NewBus create an empty bus
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[string]map[chan Message]struct{})}
+}
+
+// Subscribe receive the messages of topic (or AllTopics) on a channel buffered with size,
+// call the returned function to unsubscribe, the channel is closed then
+func (bus *Bus) Subscribe(topic string, size int) (<-chan Message, func()) {
+	ch := make(chan Message, size)
+	bus.mutex.Lock()
+	if bus.subscribers[topic] == nil {
+		bus.subscribers[topic] = make(map[chan Message]struct{})
+	}
+	bus.subscribers[topic][ch] = struct{}{}
+	bus.mutex.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			bus.mutex.Lock()
+			delete(bus.subscribers[topic], ch)
+			bus.mutex.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish deliver payload to the subscribers of topic, a subscriber whose buffer is full misses the message
+// so that a slow worker never blocks the others. returns the number of subscribers of this process that received it,
+// with BridgeBus the message is also forwarded to the other workers
+func (bus *Bus) Publish(topic string, payload interface{}) int {
+	message := Message{Topic: topic, Payload: payload}
+	bus.mutex.RLock()
+	forward := bus.forward
+	bus.mutex.RUnlock()
+	if forward != nil {
+		go forward(message)
+	}
+	return bus.deliver(message)
+}
+
+// setForward hand the messages published from now on to forward
+func (bus *Bus) setForward(forward func(Message)) {
+	bus.mutex.Lock()
+	bus.forward = forward
+	bus.mutex.Unlock()
+}
+
+// deliver hand message to the subscribers of this process
+func (bus *Bus) deliver(message Message) int {
+	delivered := 0
+
+	bus.mutex.RLock()
+	defer bus.mutex.RUnlock()
+	for _, subscribers := range []map[chan Message]struct{}{bus.subscribers[message.Topic], bus.subscribers[AllTopics]} {
+		for ch := range subscribers {
+			select {
+			case ch <- message:
+				delivered++
+			default:
+			}
+		}
+	}
+	return delivered
+}
+
+// SetBus use bus instead of DefaultBus
+func (process *Process) SetBus(bus *Bus) *Process {
+	return process.configure("SetBus", func() {
+		process.bus = bus
+	})
+}
+
+// Bus the bus of the process, DefaultBus unless SetBus is used
+func (process *Process) Bus() *Bus {
+	if process.bus == nil {
+		return DefaultBus
+	}
+	return process.bus
+}
@@ -0,0 +1,53 @@
+package daemon
+
+import "testing"
+
+func TestBusPublish(t *testing.T) {
+	tests := []struct {
+		name      string
+		topics    []string // one subscriber per topic, buffered with 1
+		publish   []string // topics published in order
+		delivered []int    // what Publish returned for each
+		received  []int    // messages waiting on each subscriber
+	}{
+		{"no subscriber", nil, []string{"config"}, []int{0}, nil},
+		{"topic", []string{"config", "drain"}, []string{"config"}, []int{1}, []int{1, 0}},
+		{"all topics", []string{AllTopics, "drain"}, []string{"config", "drain"}, []int{1, 1}, []int{1, 1}},
+		{"both", []string{AllTopics, "config"}, []string{"config"}, []int{2}, []int{1, 1}},
+		{"full buffer", []string{"config"}, []string{"config", "config"}, []int{1, 0}, []int{1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bus := NewBus()
+			var channels []<-chan Message
+			for _, topic := range test.topics {
+				ch, unsubscribe := bus.Subscribe(topic, 1)
+				defer unsubscribe()
+				channels = append(channels, ch)
+			}
+			for i, topic := range test.publish {
+				if delivered := bus.Publish(topic, i); delivered != test.delivered[i] {
+					t.Errorf("Publish(%q) = %d, want %d", topic, delivered, test.delivered[i])
+				}
+			}
+			for i, ch := range channels {
+				if len(ch) != test.received[i] {
+					t.Errorf("subscriber of %q has %d messages, want %d", test.topics[i], len(ch), test.received[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBusUnsubscribe(t *testing.T) {
+	bus := NewBus()
+	ch, unsubscribe := bus.Subscribe("config", 1)
+	unsubscribe()
+	unsubscribe()
+	if _, open := <-ch; open {
+		t.Fatal("the channel is still open after unsubscribe")
+	}
+	if delivered := bus.Publish("config", nil); delivered != 0 {
+		t.Errorf("Publish delivered to %d subscribers after unsubscribe", delivered)
+	}
+}
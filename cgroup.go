@@ -0,0 +1,26 @@
+package daemon
+
+import "time"
+
+// cgroupPollInterval how often the events of the cgroup are checked
+const cgroupPollInterval = 10 * time.Second
+
+// Cgroup a cgroup v2 the child is placed in on Linux, a lightweight containment without containers.
+// the whole process tree of the worker shares its limits
+type Cgroup struct {
+	// Parent directory below the root of the cgroup v2 hierarchy, the cgroup is Parent/<name>. the root when empty
+	Parent string
+	// CPU CPUs the worker can use, cpu.max, 0.5 is half a CPU. zero leaves it unlimited
+	CPU float64
+	// Memory bytes the worker can use, memory.max. zero leaves it unlimited
+	Memory int64
+}
+
+// SetCgroup place the child in a new cgroup with the limits of cgroup, before worker.Start. the controllers are enabled
+// in the cgroups above it, the child has to start as root or with the hierarchy delegated to its user.
+// reaching the limits (out of memory kills, CPU throttling) is reported to the logger
+func (process *Process) SetCgroup(cgroup Cgroup) *Process {
+	return process.configure("SetCgroup", func() {
+		process.cgroup = &cgroup
+	})
+}
@@ -0,0 +1,139 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cgroupCPUPeriod the period of cpu.max in microseconds, the default of the kernel
+const cgroupCPUPeriod = 100000
+
+// cgroupMount the mount point of the cgroup v2 hierarchy
+func cgroupMount() (string, error) {
+	data, err := ioutil.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// the fields after " - " are the filesystem type, the source and the super options
+		line := strings.SplitN(scanner.Text(), " - ", 2)
+		fields := strings.Fields(line[0])
+		if len(line) == 2 && len(fields) > 4 && strings.HasPrefix(line[1], "cgroup2 ") {
+			return fields[4], nil
+		}
+	}
+	return "", errors.New("cgroup v2 is not mounted")
+}
+
+// joinCgroup create the cgroup of SetCgroup with its limits and move the process into it. a new child started on restart
+// is already in it and only applies the limits again, a supervised worker is started in the cgroup of its supervisor
+func (process *Process) joinCgroup() error {
+	if process.cgroup == nil || process.supervised() {
+		return nil
+	}
+	mount, err := cgroupMount()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(mount, process.cgroup.Parent, process.worker.Name())
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var controllers []string
+	if process.cgroup.CPU > 0 {
+		controllers = append(controllers, "+cpu")
+	}
+	if process.cgroup.Memory > 0 {
+		controllers = append(controllers, "+memory")
+	}
+	// every cgroup above dir hands the controllers down, from the root
+	if len(controllers) > 0 {
+		var parents []string
+		for parent := filepath.Dir(dir); parent != mount && strings.HasPrefix(parent, mount); parent = filepath.Dir(parent) {
+			parents = append(parents, parent)
+		}
+		parents = append(parents, mount)
+		for i := len(parents) - 1; i >= 0; i-- {
+			if err = cgroupWrite(parents[i], "cgroup.subtree_control", strings.Join(controllers, " ")); err != nil {
+				return err
+			}
+		}
+	}
+
+	if process.cgroup.CPU > 0 {
+		quota := int(process.cgroup.CPU * cgroupCPUPeriod)
+		if err = cgroupWrite(dir, "cpu.max", fmt.Sprintf("%d %d", quota, cgroupCPUPeriod)); err != nil {
+			return err
+		}
+	}
+	if process.cgroup.Memory > 0 {
+		if err = cgroupWrite(dir, "memory.max", strconv.FormatInt(process.cgroup.Memory, 10)); err != nil {
+			return err
+		}
+	}
+	if err = cgroupWrite(dir, "cgroup.procs", strconv.Itoa(os.Getpid())); err != nil {
+		return err
+	}
+	process.info("cgroup joined", "cgroup", dir, "cpu", process.cgroup.CPU, "memory", process.cgroup.Memory)
+	go process.watchCgroup(dir)
+	return nil
+}
+
+// cgroupWrite write value to the file called name of the cgroup dir
+func cgroupWrite(dir, name, value string) error {
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(value), 0644); err != nil {
+		return fmt.Errorf("cgroup %s: %v", dir, err)
+	}
+	return nil
+}
+
+// cgroupCounters the "key value" lines of a file of the cgroup dir, such as memory.events
+func cgroupCounters(dir, name string) map[string]int64 {
+	data, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return nil
+	}
+	counters := make(map[string]int64)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			counters[fields[0]] = value
+		}
+	}
+	return counters
+}
+
+// watchCgroup report the limits of the cgroup dir being reached, until the process exits
+func (process *Process) watchCgroup(dir string) {
+	memory, cpu := cgroupCounters(dir, "memory.events"), cgroupCounters(dir, "cpu.stat")
+	for range time.Tick(cgroupPollInterval) {
+		if current := cgroupCounters(dir, "memory.events"); current != nil {
+			if current["oom_kill"] > memory["oom_kill"] || current["oom"] > memory["oom"] {
+				process.error("out of memory", "cgroup", dir, "oom", current["oom"]-memory["oom"], "oom_kill", current["oom_kill"]-memory["oom_kill"])
+			} else if current["max"] > memory["max"] {
+				process.error("memory limit reached", "cgroup", dir, "times", current["max"]-memory["max"])
+			}
+			memory = current
+		}
+		if current := cgroupCounters(dir, "cpu.stat"); current != nil {
+			if process.cgroup.CPU > 0 && current["nr_throttled"] > cpu["nr_throttled"] {
+				throttled := time.Duration(current["throttled_usec"]-cpu["throttled_usec"]) * time.Microsecond
+				process.info("cpu throttled", "cgroup", dir, "periods", current["nr_throttled"]-cpu["nr_throttled"], "throttled", throttled)
+			}
+			cpu = current
+		}
+	}
+}
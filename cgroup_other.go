@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package daemon
+
+// joinCgroup cgroups only exist on Linux
+func (process *Process) joinCgroup() error {
+	if process.cgroup == nil {
+		return nil
+	}
+	return ErrUnsupported
+}
+
+// cgroupMount cgroups only exist on Linux
+func cgroupMount() (string, error) {
+	return "", ErrUnsupported
+}
@@ -0,0 +1,63 @@
+package daemon
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Chaos bounds of the failures injected in chaos mode, so teams can verify their workers
+// and supervision policies tolerate daemon-level failures. zero values disable the corresponding failure
+type Chaos struct {
+	MaxRestartInterval time.Duration // the child restarts itself at a random moment within this interval, again and again
+	MaxStopDelay       time.Duration // stop is delayed by a random duration up to this
+	DropReadiness      float64       // probability, between 0 and 1, that a started child never reports it is ready
+}
+
+// DefaultChaos used by start --chaos and the chaos setting of a config file when SetChaos was not called
+var DefaultChaos = Chaos{MaxRestartInterval: 10 * time.Minute, MaxStopDelay: 5 * time.Second, DropReadiness: 0.1}
+
+// SetChaos enable chaos mode, never do this in production
+func (process *Process) SetChaos(chaos Chaos) *Process {
+	return process.configure("SetChaos", func() {
+		process.chaos = &chaos
+	})
+}
+
+// random a random duration in [0, max)
+func random(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// injectRestarts restart the child at random moments
+func (process *Process) injectRestarts() {
+	if process.chaos == nil || process.chaos.MaxRestartInterval <= 0 {
+		return
+	}
+	go func() {
+		time.Sleep(random(process.chaos.MaxRestartInterval))
+		process.info("chaos: injecting a restart")
+		_ = process.requestRestart("chaos")
+	}()
+}
+
+// injectStopDelay delay the stop by a random duration
+func (process *Process) injectStopDelay() {
+	if process.chaos == nil || process.chaos.MaxStopDelay <= 0 {
+		return
+	}
+	delay := random(process.chaos.MaxStopDelay)
+	process.info("chaos: delaying stop", "delay", delay)
+	time.Sleep(delay)
+}
+
+// dropReadiness in the child, decide whether the worker never becomes ready this time
+func (process *Process) dropReadiness() {
+	if process.chaos == nil || rand.Float64() >= process.chaos.DropReadiness {
+		return
+	}
+	process.info("chaos: dropping readiness")
+	process.readyDropped = true
+}
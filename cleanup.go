@@ -0,0 +1,48 @@
+package daemon
+
+import (
+	"os"
+	"syscall"
+)
+
+// addArtifact register a file that belongs to a running instance, such as a control socket,
+// it is removed at start when the previous instance is no longer alive
+func (process *Process) addArtifact(filename string) {
+	process.artifacts = append(process.artifacts, filename)
+}
+
+// cleanup detect leftovers of a crashed previous run before spawning a new child, and report what was found
+func (process *Process) cleanup() {
+	pid, err := process.pid.Read()
+	if err != nil && os.IsNotExist(err) {
+		process.removeArtifacts()
+		return
+	}
+	if !process.pid.IsStale() {
+		// still running, the child reports it when it cannot lock the pid file
+		return
+	}
+
+	if err = process.pid.forget(process.pid.SaveFilename()); err == nil {
+		process.info("removed stale pid file", "file", process.pid.SaveFilename())
+	}
+	if pid > 0 && alive(pid) && process.pid.verify(pid) != nil {
+		// the pid was reused by another program, its group is not ours
+		process.removeArtifacts()
+		return
+	}
+	if pid > 0 && groupAlive(pid) {
+		process.info("processes of the previous run are still alive, terminating them", "group", pid)
+		_ = signalGroup(pid, syscall.SIGTERM)
+	}
+	process.removeArtifacts()
+}
+
+// removeArtifacts remove the leftover files registered by addArtifact
+func (process *Process) removeArtifacts() {
+	for _, filename := range process.artifacts {
+		if err := os.Remove(filename); err == nil {
+			process.info("removed leftover", "file", filename)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package daemon
+
+import "github.com/spf13/cobra"
+
+// Commander If the worker implements this interface, the commands it returns are added next to its start, stop and
+// restart commands, such as a migrate command. Commands is called for every node the worker is added to,
+// so it must return new commands on every call
+type Commander interface {
+	Commands() []*cobra.Command
+}
+
+// AddCommand add a command name next to the generated commands of the daemon, run is its RunE.
+// returns the command, to add flags or aliases
+func (daemon *Daemon) AddCommand(name, short string, run func(cmd *cobra.Command, args []string) error) *cobra.Command {
+	cmd := &cobra.Command{Use: name, Short: short, RunE: runE(run)}
+	daemon.command.AddCommand(cmd)
+	return cmd
+}
+
+// addCommands add the commands of a worker implementing Commander
+func (daemon *Daemon) addCommands(worker *Process) {
+	if commander, ok := worker.impl.(Commander); ok {
+		daemon.command.AddCommand(commander.Commands()...)
+	}
+}
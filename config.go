@@ -0,0 +1,256 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// Config the config file of the flag of EnableConfigFlag, YAML, or TOML with a .toml extension. the settings apply to every worker,
+// the ones of Workers, by worker name, to that worker only. they override what the code set, the environment, see
+// bindEnv and envSettings, overrides the file and the command line overrides both. relative paths are relative to the
+// directory of the file
+type Config struct {
+	Settings `yaml:",inline"`
+	Workers  map[string]Settings `yaml:"workers" toml:"workers"`
+}
+
+// Settings what a config file can set, empty values keep what the code set
+type Settings struct {
+	PidDir        string                `yaml:"pid_dir" toml:"pid_dir"`
+	PidFilename   string                `yaml:"pid_filename" toml:"pid_filename"` // see Pid.SetFilename
+	Stdout        string                `yaml:"stdout" toml:"stdout"`             // see SetLogFiles
+	Stderr        string                `yaml:"stderr" toml:"stderr"`
+	StopTimeout   string                `yaml:"stop_timeout" toml:"stop_timeout"` // such as "30s"
+	Restart       string                `yaml:"restart" toml:"restart"`           // never, on-failure or always, see WithSupervision
+	RestartPolicy RestartPolicySettings `yaml:"restart_policy" toml:"restart_policy"`
+	User          string                `yaml:"user" toml:"user"` // see SetCredentials
+	Group         string                `yaml:"group" toml:"group"`
+	Confirm       *bool                 `yaml:"confirm" toml:"confirm"` // see SetConfirm
+	Chaos         *ChaosSettings        `yaml:"chaos" toml:"chaos"`     // enables chaos mode, see SetChaos
+	Env           map[string]string     `yaml:"env" toml:"env"`         // see SetEnv
+	Flags         map[string]string     `yaml:"flags" toml:"flags"`     // values of the flags of the commands, such as the ones added by SetCommand
+}
+
+// RestartPolicySettings the fields of RestartPolicy a config file can set, durations such as "1s"
+type RestartPolicySettings struct {
+	InitialDelay string  `yaml:"initial_delay" toml:"initial_delay"`
+	Multiplier   float64 `yaml:"multiplier" toml:"multiplier"`
+	MaxDelay     string  `yaml:"max_delay" toml:"max_delay"`
+	MaxRestarts  int     `yaml:"max_restarts" toml:"max_restarts"`
+	Window       string  `yaml:"window" toml:"window"`
+}
+
+// ChaosSettings the fields of Chaos a config file can set, durations such as "10m". the empty ones keep DefaultChaos
+type ChaosSettings struct {
+	MaxRestartInterval string  `yaml:"max_restart_interval" toml:"max_restart_interval"`
+	MaxStopDelay       string  `yaml:"max_stop_delay" toml:"max_stop_delay"`
+	DropReadiness      float64 `yaml:"drop_readiness" toml:"drop_readiness"`
+}
+
+// restartModes the values of restart in a config file
+var restartModes = map[string]RestartMode{"never": RestartNever, "on-failure": RestartOnFailure, "always": RestartAlways}
+
+func init() {
+	cobra.OnInitialize(func() {
+		if command.configFlag != "" {
+			if filename, _ := command.command.PersistentFlags().GetString(command.configFlag); filename != "" {
+				command.initErr = command.loadConfig(filename)
+			}
+		}
+		if command.initErr == nil {
+			command.initErr = command.loadEnv()
+		}
+	})
+}
+
+// EnableConfigFlag add the persistent flag name, such as "config", to the root command. it reads the config file
+// given with it, see Config. errors are returned by the command that was executed
+func EnableConfigFlag(name string) {
+	command.configFlag = name
+	command.command.PersistentFlags().String(name, "", "config file of the workers, YAML or TOML")
+}
+
+// ReadConfig read the config file filename
+func ReadConfig(filename string) (*Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	config := new(Config)
+	if strings.EqualFold(filepath.Ext(filename), ".toml") {
+		var meta toml.MetaData
+		if meta, err = toml.Decode(string(data), config); err == nil && len(meta.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown setting %s", meta.Undecoded()[0])
+		}
+	} else {
+		err = yaml.UnmarshalStrict(data, config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %v", filename, err)
+	}
+	return config, nil
+}
+
+// loadConfig apply the config file filename to the workers below daemon
+func (daemon *Daemon) loadConfig(filename string) error {
+	filename = absolute(filename)
+	config, err := ReadConfig(filename)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(filename)
+	for _, node := range daemon.nodes() {
+		worker := node.worker
+		if err = worker.applySettings(config.Settings, dir); err != nil {
+			return fmt.Errorf("config %s: %v", filename, err)
+		}
+		if settings, ok := config.Workers[worker.worker.Name()]; ok {
+			if err = worker.applySettings(settings, dir); err != nil {
+				return fmt.Errorf("config %s: workers.%s: %v", filename, worker.worker.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// applySettings apply the settings of a config file in dir
+func (process *Process) applySettings(settings Settings, dir string) error {
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+	if settings.PidDir != "" {
+		process.configure("Pid.SetSavePath", func() { process.pid.SetSavePath(resolve(settings.PidDir)) })
+	}
+	if settings.PidFilename != "" {
+		process.configure("Pid.SetFilename", func() { process.pid.SetFilename(settings.PidFilename) })
+	}
+	for i, path := range []string{settings.Stdout, settings.Stderr} {
+		if path != "" {
+			i, path := i, resolve(path)
+			process.configure("SetLogFiles", func() { process.logPaths[i] = path })
+		}
+	}
+	if settings.StopTimeout != "" {
+		timeout, err := time.ParseDuration(settings.StopTimeout)
+		if err != nil {
+			return fmt.Errorf("stop_timeout: %v", err)
+		}
+		process.SetStopTimeout(timeout)
+	}
+	if settings.Restart != "" {
+		mode, ok := restartModes[settings.Restart]
+		if !ok {
+			return fmt.Errorf("restart: %q is not never, on-failure or always", settings.Restart)
+		}
+		process.WithSupervision(mode)
+	}
+	if err := settings.RestartPolicy.apply(&process.restartPolicy); err != nil {
+		return err
+	}
+	if settings.User != "" {
+		process.SetCredentials(settings.User, settings.Group)
+	}
+	if settings.Chaos != nil {
+		chaos, err := settings.Chaos.chaos()
+		if err != nil {
+			return err
+		}
+		process.SetChaos(chaos)
+	}
+	if settings.Confirm != nil {
+		process.SetConfirm(*settings.Confirm)
+	}
+	process.SetEnv(settings.Env)
+
+	for name, value := range settings.Flags {
+		for _, cmd := range process.commands {
+			if err := setDefaultFlag(cmd, name, value); err != nil {
+				return fmt.Errorf("flags.%s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// apply override the fields of policy that are set
+func (settings RestartPolicySettings) apply(policy *RestartPolicy) error {
+	for name, field := range map[string]struct {
+		value string
+		into  *time.Duration
+	}{
+		"initial_delay": {settings.InitialDelay, &policy.InitialDelay},
+		"max_delay":     {settings.MaxDelay, &policy.MaxDelay},
+		"window":        {settings.Window, &policy.Window},
+	} {
+		if field.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(field.value)
+		if err != nil {
+			return fmt.Errorf("restart_policy.%s: %v", name, err)
+		}
+		*field.into = duration
+	}
+	if settings.Multiplier != 0 {
+		policy.Multiplier = settings.Multiplier
+	}
+	if settings.MaxRestarts != 0 {
+		policy.MaxRestarts = settings.MaxRestarts
+	}
+	return nil
+}
+
+// chaos DefaultChaos with the fields that are set overridden
+func (settings ChaosSettings) chaos() (Chaos, error) {
+	chaos := DefaultChaos
+	for name, field := range map[string]struct {
+		value string
+		into  *time.Duration
+	}{
+		"max_restart_interval": {settings.MaxRestartInterval, &chaos.MaxRestartInterval},
+		"max_stop_delay":       {settings.MaxStopDelay, &chaos.MaxStopDelay},
+	} {
+		if field.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(field.value)
+		if err != nil {
+			return chaos, fmt.Errorf("chaos.%s: %v", name, err)
+		}
+		*field.into = duration
+	}
+	if settings.DropReadiness < 0 || settings.DropReadiness > 1 {
+		return chaos, fmt.Errorf("chaos.drop_readiness: %v is not between 0 and 1", settings.DropReadiness)
+	}
+	if settings.DropReadiness != 0 {
+		chaos.DropReadiness = settings.DropReadiness
+	}
+	return chaos, nil
+}
+
+// setDefaultFlag set the flag name of cmd to value unless it was given on the command line or in the environment
+func setDefaultFlag(cmd *cobra.Command, name, value string) error {
+	// merge the persistent flags, otherwise they are only visible on the command that was executed
+	cmd.LocalFlags()
+	flag := cmd.Flags().Lookup(name)
+	if flag == nil || flag.Changed {
+		return nil
+	}
+	if key := flagEnv(name); key != "" {
+		if _, ok := os.LookupEnv(key); ok {
+			return nil
+		}
+	}
+	return cmd.Flags().Set(name, value)
+}
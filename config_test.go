@@ -0,0 +1,143 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		content  string
+		want     *Config
+		err      string
+	}{
+		{"yaml", "app.yaml", "pid_dir: run\nstop_timeout: 5s\nworkers:\n  http:\n    stdout: http.log\n",
+			&Config{Settings: Settings{PidDir: "run", StopTimeout: "5s"}, Workers: map[string]Settings{"http": {Stdout: "http.log"}}}, ""},
+		{"toml", "app.TOML", "pid_dir = \"run\"\n[restart_policy]\nmax_restarts = 3\n[workers.http]\nenv = {A = \"1\"}\n",
+			&Config{Settings: Settings{PidDir: "run", RestartPolicy: RestartPolicySettings{MaxRestarts: 3}},
+				Workers: map[string]Settings{"http": {Env: map[string]string{"A": "1"}}}}, ""},
+		{"unknown yaml setting", "app.yaml", "pid_directory: run\n", nil, "pid_directory"},
+		{"unknown toml setting", "app.toml", "pid_directory = \"run\"\n", nil, "pid_directory"},
+		{"invalid yaml", "app.yml", "pid_dir: [\n", nil, "app.yml"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "config")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			filename := filepath.Join(dir, test.filename)
+			if err = ioutil.WriteFile(filename, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			config, err := ReadConfig(filename)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("ReadConfig() error = %v, want one mentioning %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(config, test.want) {
+				t.Errorf("ReadConfig() = %+v, want %+v", config, test.want)
+			}
+		})
+	}
+}
+
+func TestApplySettings(t *testing.T) {
+	dir := filepath.Join(string(filepath.Separator), "etc", "app")
+	yes := true
+	tests := []struct {
+		name     string
+		settings Settings
+		check    func(process *Process) bool
+		err      string
+	}{
+		{"relative pid dir", Settings{PidDir: "run"}, func(process *Process) bool {
+			return process.pid.SavePath == filepath.Join(dir, "run")
+		}, ""},
+		{"pid filename", Settings{PidFilename: "%s-app.pid"}, func(process *Process) bool {
+			return process.pid.Filename == "%s-app.pid"
+		}, ""},
+		{"log files", Settings{Stdout: "out.log", Stderr: filepath.Join(dir, "..", "err.log")}, func(process *Process) bool {
+			return process.logPaths == [2]string{filepath.Join(dir, "out.log"), filepath.Join(dir, "..", "err.log")}
+		}, ""},
+		{"stop timeout", Settings{StopTimeout: "5s"}, func(process *Process) bool {
+			return process.stopTimeout == 5*time.Second
+		}, ""},
+		{"invalid stop timeout", Settings{StopTimeout: "5"}, nil, "stop_timeout"},
+		{"restart", Settings{Restart: "always"}, func(process *Process) bool {
+			return process.supervision == RestartAlways
+		}, ""},
+		{"invalid restart", Settings{Restart: "sometimes"}, nil, "restart"},
+		{"restart policy", Settings{RestartPolicy: RestartPolicySettings{InitialDelay: "2s", MaxRestarts: 3}}, func(process *Process) bool {
+			return process.restartPolicy.InitialDelay == 2*time.Second && process.restartPolicy.MaxRestarts == 3 &&
+				process.restartPolicy.MaxDelay == DefaultRestartPolicy.MaxDelay
+		}, ""},
+		{"invalid restart policy", Settings{RestartPolicy: RestartPolicySettings{Window: "long"}}, nil, "restart_policy.window"},
+		{"user", Settings{User: "www-data"}, func(process *Process) bool {
+			return process.credentials != nil && process.credentials.user == "www-data"
+		}, ""},
+		{"env", Settings{Env: map[string]string{"A": "1"}}, func(process *Process) bool {
+			return process.env["A"] == "1"
+		}, ""},
+		{"confirm", Settings{Confirm: &yes}, func(process *Process) bool {
+			return process.confirmation
+		}, ""},
+		{"chaos", Settings{Chaos: &ChaosSettings{MaxStopDelay: "1s"}}, func(process *Process) bool {
+			return process.chaos != nil && process.chaos.MaxStopDelay == time.Second &&
+				process.chaos.MaxRestartInterval == DefaultChaos.MaxRestartInterval
+		}, ""},
+		{"invalid chaos", Settings{Chaos: &ChaosSettings{DropReadiness: 2}}, nil, "chaos.drop_readiness"},
+		{"empty", Settings{}, func(process *Process) bool {
+			return process.stopTimeout == DefaultStopTimeout && process.supervision == RestartNever &&
+				process.logPaths == [2]string{} && process.chaos == nil && !process.confirmation
+		}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			process := NewProcess(testWorker{dir: "/var/run", name: "config"})
+			err := process.applySettings(test.settings, dir)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("applySettings() error = %v, want one mentioning %s", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !test.check(process) {
+				t.Errorf("applySettings(%+v) was not applied", test.settings)
+			}
+		})
+	}
+}
+
+func TestApplySettingsFrozen(t *testing.T) {
+	process := NewProcess(testWorker{dir: "/var/run", name: "config"})
+	process.SetLogger(discardLogger{})
+	process.freeze()
+	savePath := process.pid.SavePath
+	if err := process.applySettings(Settings{PidDir: "run", Stdout: "out.log"}, "/etc/app"); err != nil {
+		t.Fatal(err)
+	}
+	if process.pid.SavePath != savePath || process.logPaths != [2]string{} {
+		t.Errorf("the settings were applied once running: %s %v", process.pid.SavePath, process.logPaths)
+	}
+	if process.Err() == nil {
+		t.Error("Err() = nil, want the ignored setters")
+	}
+}
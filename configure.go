@@ -0,0 +1,33 @@
+package daemon
+
+import "fmt"
+
+// configure apply set, the change of setter, unless Run started. the fields are read without locking while the
+// worker runs, so a late setter is ignored and reported by Err instead of racing with them
+func (process *Process) configure(setter string, set func()) *Process {
+	process.configMutex.Lock()
+	defer process.configMutex.Unlock()
+	if process.frozen {
+		if process.configErr == nil {
+			process.configErr = fmt.Errorf("%s: %w", setter, ErrRunning)
+		}
+		process.error("setter called while running, ignored", "setter", setter)
+		return process
+	}
+	set()
+	return process
+}
+
+// freeze reject the setters from now on, the signal handlers of On and Off can still change
+func (process *Process) freeze() {
+	process.configMutex.Lock()
+	process.frozen = true
+	process.configMutex.Unlock()
+}
+
+// Err the error of the first setter called once Run started, it wraps ErrRunning. nil if there was none
+func (process *Process) Err() error {
+	process.configMutex.Lock()
+	defer process.configMutex.Unlock()
+	return process.configErr
+}
@@ -0,0 +1,48 @@
+package daemon
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// SetConfirm ask the operator for confirmation before destructive operations such as stop,
+// generally enabled on production instances. the --yes flag skips the question
+func (process *Process) SetConfirm(confirm bool) *Process {
+	return process.configure("SetConfirm", func() {
+		process.confirmation = confirm
+	})
+}
+
+// addConfirmFlag register the --yes flag on a destructive command
+func addConfirmFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolP("yes", "y", false, "do not ask for confirmation")
+}
+
+// confirm returns whether the operator agreed to action, always true when confirmation is not required or --yes is passed.
+// without a terminal to ask on, the operation is refused
+func (process *Process) confirm(cmd *cobra.Command, action string) bool {
+	if !process.confirmation {
+		return true
+	}
+	if yes, err := cmd.Flags().GetBool("yes"); err == nil && yes {
+		return true
+	}
+
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		fmt.Fprintf(os.Stderr, "%s %s requires confirmation, pass --yes to run it non-interactively\n", action, process.worker.Name())
+		return false
+	}
+
+	fmt.Printf("%s %s? [y/N] ", action, process.worker.Name())
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
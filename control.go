@@ -0,0 +1,345 @@
+package daemon
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// requests of the control protocol, one line per request, answered by a line starting with "ok " or "error "
+const (
+	ControlStatus  = "status"
+	ControlStop    = "stop"
+	ControlRestart = "restart"
+	ControlReload  = "reload"
+	ControlEnqueue = "enqueue" // the payload encoded with base64, with EnableQueue
+)
+
+// maxControlLine the longest request line, enough for the payload of an enqueue request
+const maxControlLine = 4 << 20
+
+// ControlHandler If the worker implements this interface, control requests other than the built-in ones are handed to it
+type ControlHandler interface {
+	HandleControl(command string, args []string) (string, error)
+}
+
+// EnableControl let the child listen on a unix socket next to the pid file for status, stop, restart, reload
+// and the requests of a ControlHandler. the generated commands prefer it over signals, so they get acknowledgements
+func (process *Process) EnableControl() *Process {
+	return process.configure("EnableControl", func() {
+		process.controlEnabled = true
+		process.addArtifact(process.controlSocket())
+	})
+}
+
+// ControlAuth who may send control requests, see SetControlAuth
+type ControlAuth struct {
+	// UIDs users allowed besides root and the user of the worker, checked with the peer credentials of the
+	// connection. only on Linux, elsewhere the socket stays accessible to its owner and root only
+	UIDs []int
+	// Token when set, a connection has to send it before its requests are answered. the generated commands send it
+	Token string
+}
+
+// SetControlAuth who may send control requests. by default only root and the user of the worker can,
+// see ControlAuth. the gRPC service of github.com/kenretto/daemon/grpccontrol follows it too
+func (process *Process) SetControlAuth(auth ControlAuth) *Process {
+	return process.configure("SetControlAuth", func() {
+		process.controlAuth = auth
+	})
+}
+
+// ControlAuth who may send control requests
+func (process *Process) ControlAuth() ControlAuth {
+	return process.controlAuth
+}
+
+// AuthorizePeer fail with ErrPermission when the process on the other end of the unix socket conn is not run by
+// root, the user of the worker or one of the UIDs of the ControlAuth. where the peer credentials can't be read,
+// it fails when UIDs are set and otherwise leaves it to the mode of the socket. other connections are not checked
+func (process *Process) AuthorizePeer(conn net.Conn) error {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil
+	}
+	uid, err := peerUID(unixConn)
+	if err == ErrUnsupported {
+		if len(process.controlAuth.UIDs) > 0 {
+			return fmt.Errorf("peer credentials: %w", ErrPermission)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("peer credentials: %w", err)
+	}
+	if uid == 0 || uid == os.Getuid() {
+		return nil
+	}
+	for _, allowed := range process.controlAuth.UIDs {
+		if uid == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("uid %d: %w", uid, ErrPermission)
+}
+
+// AuthorizeToken fail with ErrPermission when the Token of the ControlAuth is set and token is not it
+func (process *Process) AuthorizeToken(token string) error {
+	expected := process.controlAuth.Token
+	if expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1 {
+		return nil
+	}
+	return fmt.Errorf("invalid token: %w", ErrPermission)
+}
+
+// controlSocket the path of the control socket
+func (process *Process) controlSocket() string {
+	return filepath.Join(filepath.Dir(process.pid.SaveFilename()), process.pid.ServicesName+".sock")
+}
+
+// serveControl in the child, answer the control requests
+func (process *Process) serveControl() error {
+	if !process.controlEnabled {
+		return nil
+	}
+	_ = os.Remove(process.controlSocket())
+	listener, err := net.Listen("unix", process.controlSocket())
+	if err != nil {
+		return err
+	}
+	process.controlListener = listener
+	// other users reach it only when they are allowed by their peer credentials, so never where they can't be read
+	mode := os.FileMode(0600)
+	if peerCredentials && len(process.controlAuth.UIDs) > 0 {
+		mode = 0666
+	}
+	if err = os.Chmod(process.controlSocket(), mode); err != nil {
+		_ = listener.Close()
+		return err
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go process.handleControl(conn)
+		}
+	}()
+	return nil
+}
+
+// closeControl stop answering control requests and remove the socket, the bus socket of BridgeBus too
+func (process *Process) closeControl() {
+	process.closeBus()
+	if process.controlListener != nil {
+		_ = process.controlListener.Close()
+		_ = process.pid.remove(process.controlSocket())
+	}
+}
+
+// handleControl answer the requests of one connection. stop and restart keep the connection open,
+// it is closed when the process exits, which tells the client the operation is over
+func (process *Process) handleControl(conn net.Conn) {
+	if err := process.AuthorizePeer(conn); err != nil {
+		fmt.Fprintf(conn, "error %s\n", err)
+		_ = conn.Close()
+		return
+	}
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), maxControlLine)
+	// with a token, the first line is "auth <token>"
+	if process.controlAuth.Token != "" {
+		var token string
+		if scanner.Scan() {
+			token = strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "auth "))
+		}
+		if err := process.AuthorizeToken(token); err != nil {
+			fmt.Fprintf(conn, "error %s\n", err)
+			_ = conn.Close()
+			return
+		}
+		fmt.Fprintf(conn, "ok authenticated\n")
+	}
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		reply, err := process.control(fields[0], fields[1:])
+		if err != nil {
+			fmt.Fprintf(conn, "error %s\n", err)
+			continue
+		}
+		fmt.Fprintf(conn, "ok %s\n", reply)
+		if fields[0] == ControlStop || fields[0] == ControlRestart {
+			return
+		}
+	}
+	_ = conn.Close()
+}
+
+// control execute one control request
+func (process *Process) control(command string, args []string) (string, error) {
+	switch command {
+	case ControlStatus:
+		state, err := process.State()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("running pid=%d uptime=%s", state.Pid, state.Uptime()), nil
+	case ControlStop:
+		return "stopping", process.signalOwner(process.stopSignal)
+	case ControlRestart:
+		return "restarting", process.signalOwner(process.restartSignal)
+	case ControlReload:
+		return "reloading", process.signalSelf(syscall.SIGHUP)
+	case ControlEnqueue:
+		if process.queueEnabled {
+			return "enqueued", process.enqueueControl(args)
+		}
+	}
+
+	if handler, ok := process.impl.(ControlHandler); ok {
+		return handler.HandleControl(command, args)
+	}
+	return "", fmt.Errorf("unknown command %q", command)
+}
+
+// Control execute the control request command in the child, as if it was received on the control socket,
+// for other transports such as the gRPC service of github.com/kenretto/daemon/grpccontrol
+func (process *Process) Control(command string, args ...string) (string, error) {
+	return process.control(command, args)
+}
+
+// signalSelf hand sig to the signal dispatcher, so requests follow the same rules as signals
+func (process *Process) signalSelf(sig os.Signal) error {
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		return err
+	}
+	return self.Signal(sig)
+}
+
+// signalOwner hand sig to the process recorded in the pid file: the supervisor of a supervised worker,
+// which stops or replaces the worker itself, and this process otherwise
+func (process *Process) signalOwner(sig os.Signal) error {
+	if !process.supervised() {
+		return process.signalSelf(sig)
+	}
+	pid, err := process.pid.Read()
+	if err != nil {
+		return err
+	}
+	return signalPid(pid, sig)
+}
+
+// errNoControl the control socket does not exist or nobody listens on it
+var errNoControl = errors.New("control socket unavailable")
+
+// request send a control request and return the reply, with wait it also waits up to timeout for the process to close the connection
+func (process *Process) request(line string, wait bool, timeout time.Duration) (string, error) {
+	if !process.controlEnabled {
+		return "", errNoControl
+	}
+	conn, err := net.DialTimeout("unix", process.controlSocket(), time.Second)
+	if err != nil {
+		return "", errNoControl
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	reader := bufio.NewReader(conn)
+	if process.controlAuth.Token != "" {
+		if _, err = fmt.Fprintf(conn, "auth %s\n", process.controlAuth.Token); err != nil {
+			return "", err
+		}
+		if _, err = readReply(reader); err != nil {
+			return "", err
+		}
+	}
+	if _, err = fmt.Fprintf(conn, "%s\n", line); err != nil {
+		return "", err
+	}
+	reply, err := readReply(reader)
+	if err != nil {
+		return "", err
+	}
+
+	if wait {
+		if _, err = reader.ReadString('\n'); err != io.EOF {
+			return reply, fmt.Errorf("%s: %s did not finish, %w after %s", line, process.worker.Name(), ErrTimeout, timeout)
+		}
+	}
+	return reply, nil
+}
+
+// readReply read a reply line of the control protocol, an error for "error " replies
+func readReply(reader *bufio.Reader) (string, error) {
+	reply, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	reply = strings.TrimSpace(reply)
+	if strings.HasPrefix(reply, "error ") {
+		return "", errors.New(strings.TrimPrefix(reply, "error "))
+	}
+	return strings.TrimPrefix(reply, "ok "), nil
+}
+
+// tryControl send a request through the control socket and report the reply, false when the socket is unavailable.
+// with a positive wait, it also waits for the process to close the connection and fails if it doesn't
+func (process *Process) tryControl(cmd *cobra.Command, line string, wait time.Duration) (bool, error) {
+	timeout := wait
+	if wait <= 0 {
+		timeout = waitGrace
+	}
+	verb := strings.Fields(line)[0]
+	reply, err := process.request(line, wait > 0, timeout)
+	if err == errNoControl {
+		return false, nil
+	}
+	if err != nil {
+		return true, failed(process.result(verb, ""), err, 1)
+	}
+
+	result := process.result(verb, "ok")
+	result.Message = reply
+	switch {
+	case verb == ControlStatus:
+		result.State = StateRunning
+	case verb == ControlStop && wait > 0:
+		result.State = StateStopped
+	case verb == ControlRestart && wait > 0:
+		result.State = StateRestarted
+	}
+	report(cmd, result, "%s: %s\n", process.worker.Name(), reply)
+	return true, nil
+}
+
+func control(worker *Process) *cobra.Command {
+	return &cobra.Command{
+		Use:   "control <command> [args...]",
+		Short: fmt.Sprintf("send a request to the control socket of %s", worker.worker.Name()),
+		Args:  cobra.MinimumNArgs(1),
+		RunE: runE(func(cmd *cobra.Command, args []string) error {
+			controlled, err := worker.tryControl(cmd, strings.Join(args, " "), 0)
+			if !controlled {
+				return failed(worker.result(args[0], StateNotRunning), fmt.Errorf("%s is not running or has no control socket", worker.worker.Name()), 1)
+			}
+			return err
+		}),
+	}
+}
@@ -0,0 +1,62 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+)
+
+// crashExitCode the exit code of a child whose worker panicked, the same as an unrecovered panic
+const crashExitCode = 2
+
+// OnCrash call fn with the recovered value and the stack trace when worker.Start panics, before the child exits
+func (process *Process) OnCrash(fn func(recovered interface{}, stack []byte)) *Process {
+	return process.configure("OnCrash", func() {
+		process.crashHandler = fn
+	})
+}
+
+// crashFilename the crash report next to the pid file, it is kept after the child exits
+func (process *Process) crashFilename() string {
+	return filepath.Join(filepath.Dir(process.pid.SaveFilename()), process.pid.ServicesName+".crash")
+}
+
+// stderr where the process writes the errors of the worker
+func (process *Process) stderr() io.Writer {
+	if process.outputs[1] != nil {
+		return process.outputs[1]
+	}
+	return process.pipeline[2]
+}
+
+// crash report a panic of the worker: the stack trace goes to the error output and the crash file,
+// then the crash handler and script run and the child exits
+func (process *Process) crash(recovered interface{}) {
+	stack := debug.Stack()
+	report := fmt.Sprintf("panic: %v\n\n%s", recovered, stack)
+	if stderr := process.stderr(); stderr != nil {
+		_, _ = io.WriteString(stderr, report)
+	}
+	filename := process.crashFilename()
+	body := fmt.Sprintf("time: %s\npid: %d\n%s", time.Now().Format(time.RFC3339), os.Getpid(), report)
+	if err := ioutil.WriteFile(filename, []byte(body), 0644); err != nil {
+		process.error("write crash file failed", "err", err)
+	}
+	process.error("worker panicked", "panic", recovered, "crash_file", filename)
+	process.reportStartup(fmt.Errorf("%s panicked: %v", process.worker.Name(), recovered))
+
+	if process.crashHandler != nil {
+		process.crashHandler(recovered, stack)
+	}
+	process.publish(Event{Type: EventCrashed, Reason: fmt.Sprint(recovered)})
+	process.emit(OnCrash, fmt.Sprint(recovered))
+	process.unlockAll()
+	process.closeControl()
+	process.removePid()
+	process.hookExit(crashExitCode)
+	os.Exit(crashExitCode)
+}
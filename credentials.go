@@ -0,0 +1,90 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// credentials the account the worker runs as
+type credentials struct {
+	user  string
+	group string
+}
+
+// SetCredentials run the worker as user and group, the child keeps the privileges of the start command until
+// the pid file, pipes and control socket are set up, then switches before worker.Start. an empty group is the primary
+// group of user, the supplementary groups of user are kept. the start command has to run as root (not supported on Windows)
+func (process *Process) SetCredentials(user, group string) *Process {
+	return process.configure("SetCredentials", func() {
+		process.credentials = &credentials{user: user, group: group}
+	})
+}
+
+// lookup resolve the ids of the account
+func (credentials *credentials) lookup() (uid, gid int, groups []int, err error) {
+	account, err := user.Lookup(credentials.user)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if uid, err = strconv.Atoi(account.Uid); err != nil {
+		return 0, 0, nil, err
+	}
+
+	gid, err = strconv.Atoi(account.Gid)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if credentials.group != "" {
+		group, err := user.LookupGroup(credentials.group)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		if gid, err = strconv.Atoi(group.Gid); err != nil {
+			return 0, 0, nil, err
+		}
+	}
+
+	groups = []int{gid}
+	ids, err := account.GroupIds()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	for _, id := range ids {
+		if number, err := strconv.Atoi(id); err == nil && number != gid {
+			groups = append(groups, number)
+		}
+	}
+	return uid, gid, groups, nil
+}
+
+// dropPrivileges switch to the credentials of the worker, giving the files of the running instance to it
+// so that it can still clean them up. a new child started on restart already runs as the user and keeps it.
+// the chroot of SetChroot is entered in between, after the account was looked up and while still root
+func (process *Process) dropPrivileges() error {
+	if process.credentials == nil {
+		return process.enterChroot()
+	}
+	uid, gid, groups, err := process.credentials.lookup()
+	if err != nil {
+		return fmt.Errorf("credentials of %s: %v", process.worker.Name(), err)
+	}
+	if os.Getuid() == uid && os.Getgid() == gid {
+		return process.enterChroot()
+	}
+
+	for _, file := range append([]string{process.pid.SaveFilename()}, process.artifacts...) {
+		if err := os.Lchown(file, uid, gid); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := process.enterChroot(); err != nil {
+		return err
+	}
+	if err := setCredentials(uid, gid, groups); err != nil {
+		return fmt.Errorf("switch %s to user %s: %v", process.worker.Name(), process.credentials.user, err)
+	}
+	process.info("privileges dropped", "user", process.credentials.user, "uid", uid, "gid", gid)
+	return nil
+}
@@ -1,11 +1,12 @@
 package daemon
 
 import (
+	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
-	"io/ioutil"
 	"os"
-	"strconv"
+	"syscall"
+	"time"
 )
 
 var (
@@ -14,7 +15,7 @@ var (
 
 // Command Set commands to your own running worker. After all,
 // your own program will also need various parameters. If you implement this interface,
-//SetCommand will be executed before startup, passing in the cobra.Command object, which can be saved for use.
+// SetCommand will be executed before startup, passing in the cobra.Command object, which can be saved for use.
 type Command interface {
 	SetCommand(cmd *cobra.Command)
 }
@@ -23,135 +24,431 @@ func start(worker *Process) *cobra.Command {
 	start := &cobra.Command{
 		Use:   "start",
 		Short: fmt.Sprintf("start %s", worker.worker.Name()),
-		Run: func(cmd *cobra.Command, args []string) {
-			isDaemon, err := cmd.Flags().GetBool("daemon")
-			if err != nil {
-				isDaemon = true
-			}
+		RunE: runE(func(cmd *cobra.Command, args []string) error {
+			return launch(worker, cmd, args)
+		}),
+	}
 
-			// If --daemon=false is passed in, the environment variable DAEMON will be directly written as true,
-			// to allow the real program logic to run off the background.
-			if !isDaemon {
-				_ = os.Setenv(worker.DaemonTag, "true")
-			}
+	start.PersistentFlags().BoolP("daemon", "d", true, "--daemon=false is the same as --foreground")
+	start.Flags().BoolP("foreground", "f", false, "run the worker in this process, with pid file and signal handlers, until it exits")
+	start.Flags().Bool("replace", false, "gracefully stop the running instance first")
+	addDryRunFlag(start)
+	start.Flags().Bool("chaos", false, "randomly inject restarts and delayed stops, never use it in production")
+	start.Flags().Duration("wait", 0, "how long to wait for the child to report it started, defaults to 10s or until a one-shot worker completes, negative returns immediately")
+	start.Flags().Bool("wait-ready", false, "wait until the worker is ready (a Readier or the probe of SetReadyProbe), up to --wait or 1m")
+	start.Flags().Int("instances", 0, "run this many copies of the worker, see SetInstances")
+	return start
+}
 
-			err = worker.Run()
-			if err != nil {
-				if err.Error() == "resource temporarily unavailable" {
-					fmt.Println("resource temporarily unavailable")
-					os.Exit(0)
-				}
-				panic(err)
+// replace stop the running instance and wait for it to exit, so that a new one can be started
+func replace(worker *Process) error {
+	pid, err := worker.pid.load(worker.pid.SaveFilename())
+	if err != nil || !alive(pid) {
+		return nil
+	}
+
+	if err = signalPid(pid, worker.stopSignal); err != nil {
+		return err
+	}
+	if !waitExit(pid, worker.stopWait()) {
+		return fmt.Errorf("%s (pid %d) did not stop, not replacing it", worker.worker.Name(), pid)
+	}
+	return nil
+}
+
+// launch run the worker for the start command, and for restart when nothing is running
+func launch(worker *Process, cmd *cobra.Command, args []string) error {
+	if dryRun(cmd) && !worker.IsChild() {
+		return worker.planStart(cmd, StartCommand, args).print(cmd)
+	}
+	if started, err := serviceStart(worker, cmd); started || err != nil {
+		return serviceFailed(worker, StartCommand, err)
+	}
+
+	foreground := foregroundFlag(cmd)
+	if runningAsInit() {
+		// there is nothing to detach from, the container lives as long as this process
+		foreground, worker.asInit = true, true
+	}
+
+	parent := !worker.IsChild()
+	if parent {
+		if err := worker.validate(); err != nil {
+			return failed(worker.result(StartCommand, ""), err, 1)
+		}
+		worker.captureFlags(cmd)
+		if replacing, _ := cmd.Flags().GetBool("replace"); replacing {
+			if err := replace(worker); err != nil {
+				return failed(worker.result(StartCommand, ""), err, 1)
 			}
-		},
+		}
+	}
+	worker.captureArgs(cmd, args)
+	if chaos, _ := cmd.Flags().GetBool("chaos"); chaos && worker.chaos == nil {
+		worker.SetChaos(DefaultChaos)
 	}
 
-	start.PersistentFlags().BoolP("daemon", "d", true, "--daemon=false")
-	return start
+	// in the foreground the worker runs in this process, as if it were the child
+	worker.foreground = foreground
+	if parent && !foreground {
+		waitReady, _ := cmd.Flags().GetBool("wait-ready")
+		worker.startWait = startWait(cmd, worker.oneShot, waitReady)
+		if n := worker.instanceCount(cmd); n > 1 {
+			return worker.launchInstances(cmd, n)
+		}
+	}
+	return launchChild(worker, cmd, parent)
+}
+
+// serviceFailed the error of verb once the Service Control Manager handled it, nil when it succeeded
+func serviceFailed(worker *Process, verb string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return failed(worker.result(verb, ""), err, 1)
+}
+
+// foregroundFlag whether cmd runs the worker in the foreground, with --foreground or --daemon=false
+func foregroundFlag(cmd *cobra.Command) bool {
+	foreground, _ := cmd.Flags().GetBool("foreground")
+	if isDaemon, err := cmd.Flags().GetBool("daemon"); err == nil && !isDaemon {
+		foreground = true
+	}
+	return foreground
+}
+
+// launchChild run the worker, in the parent spawn the child and wait for it to start
+func launchChild(worker *Process, cmd *cobra.Command, parent bool) error {
+	foreground := worker.foreground
+	waitReady, _ := cmd.Flags().GetBool("wait-ready")
+	started := time.Now()
+	err := worker.Run()
+	if err == nil && parent && !foreground {
+		err = worker.waitStartup(worker.startWait)
+	}
+	if err == nil && parent && !foreground && waitReady {
+		err = worker.waitReady(started.Add(worker.startWait))
+	}
+	if errors.Is(err, ErrAlreadyRunning) {
+		result := worker.result(StartCommand, StateRunning)
+		result.Error = err.Error()
+		report(cmd, result, "%s is already running\n", worker.pid.ServicesName)
+		return nil
+	}
+	if exit, ok := err.(*ExitError); ok {
+		result := worker.result(StartCommand, StateCompleted)
+		result.ExitCode = exit.Code
+		return failed(result, err, exit.Code)
+	}
+	if err != nil {
+		// in the child, the error goes to its error output
+		return failed(worker.result(StartCommand, ""), err, 1)
+	}
+	if parent && !foreground {
+		result := worker.result(StartCommand, StateStarted)
+		if worker.oneShot && worker.startWait > 0 {
+			result.State = StateCompleted
+		}
+		result.Pid = worker.spawned
+		report(cmd, result, "")
+	}
+	return nil
 }
 
 func stop(worker *Process) *cobra.Command {
-	return &cobra.Command{
+	stop := &cobra.Command{
 		Use:   "stop",
 		Short: fmt.Sprintf("stop %s", worker.worker.Name()),
-		Run: func(cmd *cobra.Command, args []string) {
-			data, err := ioutil.ReadFile(worker.Pid.SaveFilename())
-			if err != nil {
-				if os.IsNotExist(err) {
-					return
+		RunE: runE(func(cmd *cobra.Command, args []string) error {
+			all, _ := cmd.Flags().GetBool("all-instances")
+			if dryRun(cmd) {
+				filenames := append([]string{worker.pid.SaveFilename()}, worker.instanceFiles()...)
+				if all {
+					instances, _ := worker.pid.Instances()
+					filenames = append(filenames[:1], instances...)
+				}
+				plan := worker.planSignal(StopCommand, filenames, worker.stopSignal, waitText(worker.waitFlag(cmd)))
+				if force, _ := cmd.Flags().GetBool("force"); force {
+					plan.add("kill the processes that did not exit with SIGKILL")
 				}
-				panic(err)
+				return plan.print(cmd)
 			}
-			pid, err := strconv.Atoi(string(data))
-			if err != nil {
-				panic(err)
+			if !worker.confirm(cmd, "stop") {
+				return exitWith(1)
 			}
-			process, err := os.FindProcess(pid)
-			if err != nil {
-				panic(err)
+			if stopped, err := serviceStop(worker); stopped || err != nil {
+				return serviceFailed(worker, StopCommand, err)
+			}
+
+			wait := worker.waitFlag(cmd)
+			if !all {
+				if controlled, err := worker.tryControl(cmd, ControlStop, wait); controlled || err != nil {
+					return err
+				}
+			}
+
+			filenames := append([]string{worker.pid.SaveFilename()}, worker.instanceFiles()...)
+			if all {
+				filenames = filenames[:1]
+				instances, err := worker.pid.Instances()
+				if err != nil {
+					return failed(worker.result(StopCommand, ""), err, 1)
+				}
+				filenames = append(filenames, instances...)
 			}
-			_ = process.Signal(SIGUSR1)
-		},
+
+			var stopping []int
+			stopped := make(map[int]string) // the pid files of the stopping processes
+			seen := make(map[string]bool)   // a pattern with %d names the worker run alone like instance 0
+			for _, filename := range filenames {
+				if seen[filename] {
+					continue
+				}
+				seen[filename] = true
+				pid, err := worker.pid.signal(filename, worker.stopSignal)
+				switch {
+				case err == nil:
+					stopping = append(stopping, pid)
+					stopped[pid] = filename
+				case os.IsNotExist(err):
+				case errors.Is(err, syscall.ESRCH):
+					// stopping something that is already stopped only has to clean up after it
+					if errors.Is(err, ErrStalePid) {
+						worker.info("not signaling", "file", filename, "error", err)
+					}
+					_ = worker.pid.forget(filename)
+					worker.removeArtifacts()
+				default:
+					return failed(worker.result(StopCommand, ""), err, 1)
+				}
+			}
+
+			if len(stopping) == 0 {
+				report(cmd, worker.result(StopCommand, StateNotRunning), "%s is not running\n", worker.worker.Name())
+				if worker.lsb {
+					return exitWith(ExitNotRunning)
+				}
+				return nil
+			}
+			if wait < 0 {
+				report(cmd, worker.result(StopCommand, "stopping"), "")
+				return nil
+			}
+			force, _ := cmd.Flags().GetBool("force")
+			for _, pid := range stopping {
+				if waitExit(pid, wait) {
+					continue
+				}
+				if !force {
+					result := worker.result(StopCommand, StateRunning)
+					result.Pid = pid
+					return failed(result, fmt.Errorf("%s (pid %d) did not stop, %w after %s", worker.worker.Name(), pid, ErrTimeout, wait), 1)
+				}
+				if err := worker.forceKill(pid, stopped[pid]); err != nil {
+					return failed(worker.result(StopCommand, ""), err, 1)
+				}
+				if !jsonOutput(cmd) {
+					fmt.Printf("%s (pid %d) did not stop within %s, killed\n", worker.worker.Name(), pid, wait)
+				}
+			}
+			report(cmd, worker.result(StopCommand, StateStopped), "%s stopped\n", worker.worker.Name())
+			return nil
+		}),
+	}
+
+	addConfirmFlag(stop)
+	addWaitFlag(stop)
+	addDryRunFlag(stop)
+	stop.Flags().Bool("all-instances", false, "stop every instance of the worker, <pid-dir>/<name>-*.pid")
+	stop.Flags().Bool("force", false, "kill the processes that did not stop within --wait with SIGKILL")
+	return stop
+}
+
+// signalPid check that pid is alive and can be signaled before sending sig, os.FindProcess always succeeds on unix
+func signalPid(pid int, sig os.Signal) error {
+	switch err := probe(pid); err {
+	case nil:
+	case syscall.ESRCH:
+		return fmt.Errorf("process %d is %w", pid, ErrNotRunning)
+	case syscall.EPERM:
+		return fmt.Errorf("signaling process %d, run as the service user: %w", pid, ErrPermission)
+	default:
+		return err
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
 	}
+	return process.Signal(sig)
 }
 
 func restart(worker *Process) *cobra.Command {
-	return &cobra.Command{
+	restart := &cobra.Command{
 		Use:   "restart",
 		Short: fmt.Sprintf("restart %s", worker.worker.Name()),
-		Run: func(cmd *cobra.Command, args []string) {
-			data, err := ioutil.ReadFile(worker.Pid.SaveFilename())
-			if err != nil {
-				if os.IsNotExist(err) {
-					isDaemon, err := cmd.Flags().GetBool("daemon")
-					if err != nil {
-						isDaemon = true
-					}
+		RunE: runE(func(cmd *cobra.Command, args []string) error {
+			if dryRun(cmd) {
+				filenames := worker.instanceFiles()
+				if pid, err := worker.pid.Read(); err == nil && alive(pid) && !worker.pid.IsStale() {
+					filenames = append([]string{worker.pid.SaveFilename()}, filenames...)
+				}
+				if len(filenames) == 0 {
+					return worker.planStart(cmd, RestartCommand, args).print(cmd)
+				}
+				return worker.planSignal(RestartCommand, filenames, worker.restartSignal, waitText(worker.waitFlag(cmd))).print(cmd)
+			}
+			if err := worker.validate(); err != nil {
+				return failed(worker.result(RestartCommand, ""), err, 1)
+			}
+			if restarted, err := serviceRestart(worker, cmd); restarted || err != nil {
+				return serviceFailed(worker, RestartCommand, err)
+			}
 
-					if !isDaemon {
-						_ = os.Setenv(worker.DaemonTag, "true")
-					}
+			wait := worker.waitFlag(cmd)
+			if controlled, err := worker.tryControl(cmd, ControlRestart, wait); controlled || err != nil {
+				return err
+			}
+			if filenames := worker.instanceFiles(); len(filenames) > 0 {
+				return worker.restartInstances(cmd, filenames, wait)
+			}
 
-					err = worker.Run()
-					if err != nil {
-						panic(err)
-					}
-					return
+			pid, err := worker.pid.Read()
+			if worker.pid.IsStale() {
+				// the previous run died without cleaning up, the pid may even belong to another process by now
+				if !jsonOutput(cmd) {
+					fmt.Printf("%s is not running, removing stale pid file %s\n", worker.worker.Name(), worker.pid.SaveFilename())
 				}
-				panic(err)
+				_ = worker.pid.forget(worker.pid.SaveFilename())
+				err = os.ErrNotExist
 			}
-			pid, err := strconv.Atoi(string(data))
 			if err != nil {
-				panic(err)
+				return launch(worker, cmd, args)
+			}
+
+			previous, err := worker.pid.saved()
+			if err == nil {
+				err = signalPid(pid, worker.restartSignal)
 			}
-			process, err := os.FindProcess(pid)
 			if err != nil {
-				panic(err)
+				return failed(worker.result(RestartCommand, ""), err, 1)
+			}
+			if wait < 0 {
+				report(cmd, worker.result(RestartCommand, "restarting"), "")
+				return nil
+			}
+			if err = worker.waitRestarted(previous, wait); err != nil {
+				return failed(worker.result(RestartCommand, ""), err, 1)
 			}
-			_ = process.Signal(SIGUSR2)
-		},
+			report(cmd, worker.result(RestartCommand, StateRestarted), "%s restarted\n", worker.worker.Name())
+			return nil
+		}),
 	}
+
+	addWaitFlag(restart)
+	addDryRunFlag(restart)
+	return restart
 }
 
 // Daemon manager
 type Daemon struct {
 	command  *cobra.Command
 	children map[string]*Daemon
+	order    []*Daemon // children in registration order
 	parent   *Daemon
 	worker   *Process
+	verbs    map[string]*cobra.Command
+
+	dependencies []string // names of the workers started before this one by the group commands
+
+	configFlag string // name of the flag of EnableConfigFlag, on the root
+	initErr    error  // loading the config file or the environment failed, on the root
+}
+
+// attach generate the lifecycle commands of worker, apply the options and add them to daemon
+func (daemon *Daemon) attach(worker *Process, options []CommandOption) {
+	commands := map[string]*cobra.Command{
+		StartCommand:     start(worker),
+		StopCommand:      stop(worker),
+		KillCommand:      kill(worker),
+		RestartCommand:   restart(worker),
+		UpgradeCommand:   upgrade(worker),
+		StatusCommand:    status(worker),
+		EnableCommand:    enable(worker),
+		DisableCommand:   disable(worker),
+		InstallCommand:   install(worker),
+		UninstallCommand: uninstall(worker),
+		LogsCommand:      logs(worker),
+	}
+	if _, ok := worker.impl.(Reloader); ok {
+		commands[ReloadCommand] = reload(worker)
+	}
+	if worker.controlEnabled {
+		commands[ControlCommand] = control(worker)
+	}
+	if worker.selfUpdate != nil {
+		commands[SelfUpdateCommand] = selfUpdate(worker)
+	}
+	if worker.queueEnabled {
+		commands[EnqueueCommand] = enqueue(worker)
+	}
+	daemon.stopsChildren(commands[StopCommand])
+	for _, option := range options {
+		option(commands)
+	}
+
+	daemon.verbs = commands
+	for verb, cmd := range commands {
+		worker.verbNames[verb] = cmd.Name()
+	}
+	for _, verb := range verbs {
+		if cmd, ok := commands[verb]; ok {
+			daemon.command.AddCommand(cmd)
+		}
+	}
+	daemon.addCommands(worker)
 }
 
 // AddWorker add child exec process
 // chainable call to generate multi-level commands.
 // non-chained calls generate multiple sibling commands, but remember that sibling commands do not have the same name
-func (daemon *Daemon) AddWorker(worker *Process) *Daemon {
+// options can rename, alias or otherwise customize the generated start/stop/restart commands.
+func (daemon *Daemon) AddWorker(worker *Process, options ...CommandOption) *Daemon {
 	if daemon.children == nil {
 		daemon.children = make(map[string]*Daemon)
 	}
 
-	child := &Daemon{command: &cobra.Command{Use: worker.worker.Name()}, parent: daemon}
-	if _, ok := worker.worker.(Command); ok {
-		worker.worker.(Command).SetCommand(child.command)
-	}
-	child.command.AddCommand(start(worker), stop(worker), restart(worker))
+	child := &Daemon{command: &cobra.Command{Use: worker.worker.Name()}, parent: daemon, worker: worker}
+	worker.setCommand(child.command)
+	child.attach(worker, options)
 	daemon.command.AddCommand(child.command)
 	daemon.children[worker.worker.Name()] = child
+	daemon.order = append(daemon.order, child)
 	return child
 }
 
+// AddWorkerFactory like AddWorker, with a worker and a process of its own created by factory for this node,
+// so that nodes never share a worker object: adding the same factory at several levels is safe
+func (daemon *Daemon) AddWorkerFactory(factory func() Worker, options ...CommandOption) *Daemon {
+	return daemon.AddWorker(NewProcess(factory()), options...)
+}
+
+// Command get the generated cobra command of verb, nil if it was disabled
+func (daemon *Daemon) Command(verb string) *cobra.Command {
+	return daemon.verbs[verb]
+}
+
 // GetParent get parent Daemon
 func (daemon *Daemon) GetParent() *Daemon {
 	return daemon.parent
 }
 
 // Register register main service, if not, you don't have to register.
-func Register(worker *Process) {
+func Register(worker *Process, options ...CommandOption) {
 	command.parent = nil
 	command.worker = worker
-	if _, ok := worker.worker.(Command); ok {
-		worker.worker.(Command).SetCommand(command.command)
-	}
-	command.command.AddCommand(start(worker), stop(worker), restart(worker))
+	worker.setCommand(command.command)
+	command.attach(worker, options)
 }
 
 // GetCommand get main Daemon
@@ -159,9 +456,21 @@ func GetCommand() *Daemon {
 	return command
 }
 
-// Run entry point
+// Run entry point, a command that fails prints its error and exits with its exit code, the other errors, such as
+// an unknown flag, are returned
 func Run() error {
-	return command.command.Execute()
+	command.addGroupCommands()
+	command.addListCommand()
+	command.addVersionCommand()
+	if err := bindEnv(command.command); err != nil {
+		return err
+	}
+	cmd, err := command.command.ExecuteC()
+	var failure *CommandError
+	if errors.As(err, &failure) {
+		exit(cmd, failure)
+	}
+	return err
 }
 
 // Name get bin package file name
@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// validatedWorker a worker whose Validator fails the first time it runs
+type validatedWorker struct {
+	testWorker
+	calls *int
+}
+
+func (worker validatedWorker) Validate() error {
+	*worker.calls++
+	if *worker.calls == 1 {
+		return errors.New("port in use")
+	}
+	return nil
+}
+
+func TestRestartValidate(t *testing.T) {
+	sleep := lookSleep(t)
+	dir, err := ioutil.TempDir("", "validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	calls := 0
+	root := &Daemon{command: &cobra.Command{Use: "app"}}
+	root.AddWorker(NewProcess(validatedWorker{testWorker{dir: dir, name: "validated"}, &calls}))
+	running := runPid(t, dir, "validated", sleep, "30")
+	defer running.Process.Kill()
+	root.command.SetArgs([]string{"validated", "restart", "--wait", "-1s"})
+	root.command.SetOutput(ioutil.Discard)
+
+	// the running worker is left alone when the validation fails
+	var validation *ValidationError
+	if err = root.command.Execute(); !errors.As(err, &validation) {
+		t.Fatalf("restart = %v, want a ValidationError", err)
+	}
+	if !alive(running.Process.Pid) {
+		t.Fatal("the worker was signaled although the validation failed")
+	}
+
+	if err = root.command.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if !waitExit(running.Process.Pid, waitGrace) {
+		t.Error("the worker did not receive the restart signal")
+	}
+	if calls != 2 {
+		t.Errorf("Validate ran %d times, want once per restart", calls)
+	}
+}
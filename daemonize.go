@@ -0,0 +1,121 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// DaemonizeOptions how far the child is detached from the start command, the zero value keeps the terminal session,
+// working directory, umask and inherited files like previous versions
+type DaemonizeOptions struct {
+	// Setsid start the child in a new session without controlling terminal, its stdin is /dev/null unless set by SetPipeline
+	Setsid bool
+	// DoubleFork start the child through an intermediate process that exits, so the child is not a session leader
+	// and can never acquire a controlling terminal again
+	DoubleFork bool
+	// Chdir working directory of the child, usually "/" so that it does not keep a mount busy, empty keeps the current one
+	Chdir string
+	// Umask file mode creation mask of the child, zero keeps the inherited one
+	Umask os.FileMode
+	// CloseFiles do not pass the files inherited by the start command, other than stdin, stdout and stderr, to the child
+	CloseFiles bool
+}
+
+// DefaultDaemonizeOptions the traditional daemonization of unix daemons
+var DefaultDaemonizeOptions = DaemonizeOptions{Setsid: true, DoubleFork: true, Chdir: "/", Umask: 0022, CloseFiles: true}
+
+// SetDaemonizeOptions detach the child from the start command, see DefaultDaemonizeOptions. some options have no effect on Windows
+func (process *Process) SetDaemonizeOptions(options DaemonizeOptions) *Process {
+	return process.configure("SetDaemonizeOptions", func() {
+		process.daemonize = options
+		process.umaskSet = false
+	})
+}
+
+// SetWorkDir the working directory of the worker, it is entered before worker.Start, after the pid file path and the pipes
+// were resolved against the directory the command was run from. the same as the Chdir option of SetDaemonizeOptions
+func (process *Process) SetWorkDir(dir string) *Process {
+	return process.configure("SetWorkDir", func() {
+		process.daemonize.Chdir = dir
+	})
+}
+
+// SetUmask the file mode creation mask of the worker, set before worker.Start. unlike the Umask option of SetDaemonizeOptions,
+// zero is applied too
+func (process *Process) SetUmask(mask int) *Process {
+	return process.configure("SetUmask", func() {
+		process.daemonize.Umask = os.FileMode(mask)
+		process.umaskSet = true
+	})
+}
+
+// forkEnv name of the environment variable that marks the intermediate process of a double fork
+func (process *Process) forkEnv() string {
+	return process.daemonTag + "_FORK"
+}
+
+// detach apply the daemonize options to the command that starts the child
+func (process *Process) detach(cmd *exec.Cmd) {
+	options := process.daemonize
+	if options.Setsid {
+		setsid(cmd)
+		if cmd.Stdin == os.Stdin {
+			cmd.Stdin = nil
+		}
+	}
+	if options.DoubleFork {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=true", process.forkEnv()))
+	}
+	if process.startDir != "" {
+		// a new child started on restart resolves relative paths like the first one did
+		cmd.Dir = process.startDir
+	}
+	if options.CloseFiles {
+		closeInheritedFiles()
+	}
+}
+
+// intermediate whether this process is the intermediate process of a double fork
+func (process *Process) intermediate() bool {
+	return os.Getenv(process.forkEnv()) == "true"
+}
+
+// forkAgain in the intermediate process, start the child and exit, the child is adopted by init
+func (process *Process) forkAgain() error {
+	cmd := exec.Command(executable(), os.Args[1:]...)
+	cmd.Args = titled(process.procTitle(process.role()), cmd.Args)
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, process.forkEnv()+"=") {
+			cmd.Env = append(cmd.Env, env)
+		}
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = inherited()
+	process.handOffStartup(cmd)
+	err := cmd.Start()
+	process.closeStartup()
+	if err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// applyDaemonize set the working directory and umask of the daemonize options in the child,
+// after the pid file path and the pipes were resolved against the directory it was started from
+func (process *Process) applyDaemonize() error {
+	if process.daemonize.Umask != 0 || process.umaskSet {
+		umask(int(process.daemonize.Umask))
+	}
+	// with a chroot, the directory is entered inside it
+	if process.daemonize.Chdir == "" || process.chroot != "" {
+		return nil
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	process.startDir = dir
+	return os.Chdir(process.daemonize.Chdir)
+}
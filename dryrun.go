@@ -0,0 +1,178 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// StateDryRun the state reported by --dry-run, the steps are in the Plan of the result
+const StateDryRun = "dry-run"
+
+// addDryRunFlag add --dry-run to a lifecycle command
+func addDryRunFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("dry-run", false, "print the command line, environment, signals and pid files the command would use, without doing anything")
+}
+
+// dryRun whether cmd runs with --dry-run
+func dryRun(cmd *cobra.Command) bool {
+	dry, _ := cmd.Flags().GetBool("dry-run")
+	return dry
+}
+
+// plan what a command would do, printed by --dry-run
+type plan struct {
+	result Result
+	steps  []string
+}
+
+// add a step
+func (plan *plan) add(format string, args ...interface{}) {
+	plan.steps = append(plan.steps, fmt.Sprintf(format, args...))
+}
+
+// print report the steps, they are the Plan of the result with --output=json
+func (plan *plan) print(cmd *cobra.Command) error {
+	plan.result.State, plan.result.Plan = StateDryRun, plan.steps
+	report(cmd, plan.result, "%s %s (dry run):\n  %s\n", plan.result.Worker, plan.result.Command, strings.Join(plan.steps, "\n  "))
+	return nil
+}
+
+// planStart the plan of the start command, also used by restart when nothing is running
+func (process *Process) planStart(cmd *cobra.Command, verb string, args []string) *plan {
+	plan := &plan{result: process.result(verb, "")}
+	process.captureFlags(cmd)
+	delete(process.flags, "dry-run")
+	process.captureArgs(cmd, args)
+
+	filename := process.pid.SaveFilename()
+	pid, err := process.pid.load(filename)
+	switch {
+	case err == nil && alive(pid):
+		if replacing, _ := cmd.Flags().GetBool("replace"); !replacing {
+			plan.add("%s is already running as pid %d (%s), nothing would be started", process.worker.Name(), pid, filename)
+			return plan
+		}
+		plan.add("send %v to pid %d (%s) and wait %s for it to exit", process.stopSignal, pid, filename, waitText(process.stopWait()))
+	case errors.Is(err, syscall.ESRCH) || err == nil:
+		plan.add("remove the stale pid file %s", filename)
+	}
+
+	if foregroundFlag(cmd) || runningAsInit() {
+		plan.add("run the worker in this process (pid %d), pid file %s", os.Getpid(), filename)
+		return plan
+	}
+	plan.add("execute %s", executable())
+	plan.add("command line: %s", shellCommandLine(titled(process.procTitle(process.role()), dryRunArgs())))
+	environ := append(process.environ(), fmt.Sprintf("%s=true", process.daemonTag), process.flagsEnviron())
+	for _, change := range envChanges(os.Environ(), environ) {
+		plan.add("environment: %s", change)
+	}
+	if process.daemonize.Chdir != "" {
+		plan.add("working directory: %s", process.daemonize.Chdir)
+	}
+	for i, path := range process.logPaths {
+		if path != "" {
+			plan.add("%s: %s", [2]string{"standard output", "standard error"}[i], path)
+		}
+	}
+	switch process.supervision {
+	case RestartAlways:
+		plan.add("the child supervises the worker and starts it again when it exits")
+	case RestartOnFailure:
+		plan.add("the child supervises the worker and starts it again when it fails")
+	}
+	if n := process.instanceCount(cmd); n > 1 {
+		for index := 0; index < n; index++ {
+			plan.add("instance %d: %s=%d, pid file %s", index, process.instanceEnv(), index, process.instanceFilename(index))
+		}
+		return plan
+	}
+	plan.add("pid file: %s", filename)
+	return plan
+}
+
+// planSignal the plan of a command that sends sig to the processes of the pid files filenames, and waits up to wait
+func (process *Process) planSignal(verb string, filenames []string, sig os.Signal, wait string) *plan {
+	plan := &plan{result: process.result(verb, "")}
+	if process.controlEnabled {
+		plan.add("send %q on the control socket %s, or else:", verb, process.controlSocket())
+	}
+	seen := make(map[string]bool)
+	for _, filename := range filenames {
+		if seen[filename] {
+			continue
+		}
+		seen[filename] = true
+		pid, err := process.pid.load(filename)
+		switch {
+		case err == nil && alive(pid):
+			plan.add("send %v to pid %d (%s)", sig, pid, filename)
+			if wait != "" {
+				plan.add("wait %s for pid %d %s", wait, pid, map[string]string{StopCommand: "to exit", RestartCommand: "to be replaced"}[verb])
+			}
+		case os.IsNotExist(err):
+			plan.add("no pid file %s", filename)
+		default:
+			plan.add("remove the stale pid file %s: %v", filename, err)
+		}
+	}
+	return plan
+}
+
+// waitText how long a command waits for wait, empty when it does not
+func waitText(wait time.Duration) string {
+	switch {
+	case wait < 0:
+		return ""
+	case wait >= forever:
+		return "without a time limit"
+	}
+	return fmt.Sprintf("up to %s", wait)
+}
+
+// dryRunArgs the arguments the child would be started with, without --dry-run
+func dryRunArgs() []string {
+	var args []string
+	for _, arg := range os.Args {
+		if arg != "--dry-run" && !strings.HasPrefix(arg, "--dry-run=") {
+			args = append(args, arg)
+		}
+	}
+	return args
+}
+
+// envChanges the variables after sets (+NAME=value) or removes (-NAME) compared to before
+func envChanges(before, after []string) []string {
+	values := func(environ []string) map[string]string {
+		variables := make(map[string]string)
+		for _, variable := range environ {
+			parts := strings.SplitN(variable, "=", 2)
+			if len(parts) == 2 {
+				variables[parts[0]] = parts[1]
+			}
+		}
+		return variables
+	}
+	old, current := values(before), values(after)
+
+	var changes []string
+	for name, value := range current {
+		if previous, ok := old[name]; !ok || previous != value {
+			changes = append(changes, fmt.Sprintf("+%s=%s", name, value))
+		}
+	}
+	for name := range old {
+		if _, ok := current[name]; !ok {
+			changes = append(changes, "-"+name)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i][1:] < changes[j][1:] })
+	return changes
+}
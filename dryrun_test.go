@@ -0,0 +1,32 @@
+package daemon
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		before []string
+		after  []string
+		want   []string
+	}{
+		{"same", []string{"A=1", "B=2"}, []string{"B=2", "A=1"}, nil},
+		{"added", []string{"A=1"}, []string{"A=1", "DAEMON=true"}, []string{"+DAEMON=true"}},
+		{"changed", []string{"A=1"}, []string{"A=2"}, []string{"+A=2"}},
+		{"removed", []string{"A=1", "SECRET=x"}, []string{"A=1"}, []string{"-SECRET"}},
+		{"sorted by name", []string{"B=1"}, []string{"C=1", "A=1"}, []string{"+A=1", "-B", "+C=1"}},
+		{"empty value", []string{"A=1"}, []string{"A="}, []string{"+A="}},
+		{"value with =", nil, []string{"FLAGS=a=b"}, []string{"+FLAGS=a=b"}},
+		{"last one wins", []string{"A=1"}, []string{"A=2", "A=1"}, nil},
+		{"malformed ignored", []string{"A=1"}, []string{"A=1", "B"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := envChanges(test.before, test.after); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("envChanges(%q, %q) = %q, want %q", test.before, test.after, got, test.want)
+			}
+		})
+	}
+}
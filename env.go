@@ -0,0 +1,65 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// SetEnv set variables in the environment of the child, they override the inherited ones
+func (process *Process) SetEnv(env map[string]string) *Process {
+	return process.configure("SetEnv", func() {
+		if process.env == nil {
+			process.env = make(map[string]string)
+		}
+		for name, value := range env {
+			process.env[name] = value
+		}
+	})
+}
+
+// ClearEnv do not pass the environment of the start command on to the child, which may contain secrets or terminal settings,
+// except the variables named keep, the ones of the daemon itself and NOTIFY_SOCKET. the variables of SetEnv are still set
+func (process *Process) ClearEnv(keep ...string) *Process {
+	return process.configure("ClearEnv", func() {
+		process.clearEnv = true
+		process.keepEnv = append(process.keepEnv, keep...)
+	})
+}
+
+// kept whether the variable called name is passed to the child after ClearEnv
+func (process *Process) kept(name string) bool {
+	if name == process.daemonTag || strings.HasPrefix(name, process.daemonTag+"_") ||
+		strings.HasPrefix(name, EnvName+"_") || name == NotifySocketEnv {
+		return true
+	}
+	for _, keep := range process.keepEnv {
+		if keep == name {
+			return true
+		}
+	}
+	return false
+}
+
+// environ the environment the child is started with
+func (process *Process) environ() []string {
+	var environ []string
+	for _, variable := range os.Environ() {
+		name := strings.SplitN(variable, "=", 2)[0]
+		if _, ok := process.env[name]; ok || process.clearEnv && !process.kept(name) {
+			continue
+		}
+		environ = append(environ, variable)
+	}
+
+	names := make([]string, 0, len(process.env))
+	for name := range process.env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		environ = append(environ, fmt.Sprintf("%s=%s", name, process.env[name]))
+	}
+	return environ
+}
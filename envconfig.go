@@ -0,0 +1,93 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// the environment variables that configure the workers like the settings of a config file, see envSettings
+const (
+	PidPathEnv     = EnvName + "_PIDPATH"
+	LogStdoutEnv   = EnvName + "_LOG_STDOUT"
+	LogStderrEnv   = EnvName + "_LOG_STDERR"
+	StopTimeoutEnv = EnvName + "_STOP_TIMEOUT"
+	RestartEnv     = EnvName + "_RESTART"
+	UserEnv        = EnvName + "_USER"
+	GroupEnv       = EnvName + "_GROUP"
+)
+
+// unboundEnv the variables never bound to a flag: the ones the daemon passes to its children, and the settings above,
+// so that DAEMON_RESTART is not also the --restart of install
+var unboundEnv = map[string]bool{
+	EnvName + "_FORK": true, EnvName + "_FLAGS": true, ListenersEnv: true, EnvName + "_RESTARTS": true,
+	EnvName + "_LANDLOCK": true, EnvName + "_STARTUP": true, EnvName + "_SUPERVISED": true, EnvName + "_INSTANCE": true,
+	EnvName + "_RESTART_REASON": true, EnvName + "_LAST_EXIT": true,
+	PidPathEnv: true, LogStdoutEnv: true, LogStderrEnv: true, StopTimeoutEnv: true, RestartEnv: true, UserEnv: true, GroupEnv: true,
+}
+
+// flagEnv the environment variable bound to the flag name, such as DAEMON_WAIT_READY for --wait-ready, empty if none
+func flagEnv(name string) string {
+	key := EnvName + "_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+	if unboundEnv[key] {
+		return ""
+	}
+	return key
+}
+
+// bindEnv make the variables DAEMON_<FLAG> the defaults of the flags of every command below cmd, such as DAEMON_CONFIG
+// for --config or DAEMON_OUTPUT for --output. a flag given on the command line wins
+func bindEnv(cmd *cobra.Command) error {
+	var err error
+	bind := func(flag *pflag.Flag) {
+		key := flagEnv(flag.Name)
+		value, ok := os.LookupEnv(key)
+		if key == "" || !ok || err != nil {
+			return
+		}
+		if err = flag.Value.Set(value); err != nil {
+			err = fmt.Errorf("%s: %v", key, err)
+			return
+		}
+		flag.DefValue = flag.Value.String()
+	}
+	cmd.PersistentFlags().VisitAll(bind)
+	cmd.Flags().VisitAll(bind)
+	for _, child := range cmd.Commands() {
+		if err == nil {
+			err = bindEnv(child)
+		}
+	}
+	return err
+}
+
+// envSettings the settings of the DAEMON_* environment variables, for every worker
+func envSettings() Settings {
+	return Settings{
+		PidDir:      os.Getenv(PidPathEnv),
+		Stdout:      os.Getenv(LogStdoutEnv),
+		Stderr:      os.Getenv(LogStderrEnv),
+		StopTimeout: os.Getenv(StopTimeoutEnv),
+		Restart:     os.Getenv(RestartEnv),
+		User:        os.Getenv(UserEnv),
+		Group:       os.Getenv(GroupEnv),
+	}
+}
+
+// loadEnv apply the settings of the environment to the workers below daemon, over the ones of the config file
+func (daemon *Daemon) loadEnv() error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	settings := envSettings()
+	for _, node := range daemon.nodes() {
+		if err = node.worker.applySettings(settings, dir); err != nil {
+			return fmt.Errorf("environment: %v", err)
+		}
+	}
+	return nil
+}
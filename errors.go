@@ -0,0 +1,42 @@
+package daemon
+
+import (
+	"errors"
+	"runtime"
+	"syscall"
+)
+
+// the errors of the package, the returned errors wrap them so that errors.Is tells the cases apart from the message
+var (
+	// ErrAlreadyRunning the pid file is locked by a running instance of the worker
+	ErrAlreadyRunning = errors.New("already running")
+	// ErrNotRunning the process of the pid file is gone, it also matches syscall.ESRCH
+	ErrNotRunning error = &systemError{text: "not running", errno: syscall.ESRCH}
+	// ErrStalePid the pid file is invalid or its pid was reused by another program, it also matches syscall.ESRCH
+	ErrStalePid error = &systemError{text: "stale pid file", errno: syscall.ESRCH}
+	// ErrPermission the process belongs to another user, it also matches syscall.EPERM and os.ErrPermission
+	ErrPermission error = &systemError{text: "permission denied", errno: syscall.EPERM}
+	// ErrTimeout the worker did not start, stop, restart or answer in time
+	ErrTimeout = errors.New("timed out")
+	// ErrRunning a setter was called once Run started, see Process.Err
+	ErrRunning = errors.New("the process is running, configure it before Run")
+	// ErrUnsupported the feature is not available on this platform
+	ErrUnsupported = errors.New("not supported on " + runtime.GOOS)
+)
+
+// systemError an error of the package that stands for a system error, which errors.Is also matches
+type systemError struct {
+	text  string
+	errno error
+}
+
+func (err *systemError) Error() string {
+	return err.text
+}
+
+func (err *systemError) Unwrap() error {
+	return err.errno
+}
+
+// reported the errors that keep their identity when the child reports them to the start command, see waitStartup
+var reported = []error{ErrAlreadyRunning, ErrNotRunning, ErrStalePid, ErrPermission, ErrTimeout, ErrUnsupported}
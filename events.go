@@ -0,0 +1,76 @@
+package daemon
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// EventType the kind of an Event
+type EventType string
+
+// the lifecycle events of a worker
+const (
+	// EventStarting the child is set up and about to start the worker
+	EventStarting EventType = "starting"
+	// EventStarted the worker started, and is ready when it implements ReadyNotifier
+	EventStarted EventType = "started"
+	// EventSignalReceived a signal was handed to the handlers
+	EventSignalReceived EventType = "signal_received"
+	// EventStopping the worker is being stopped
+	EventStopping EventType = "stopping"
+	// EventStopped the worker stopped or exited with Code, the process exits right after
+	EventStopped EventType = "stopped"
+	// EventRestartScheduled the worker is restarted for Reason
+	EventRestartScheduled EventType = "restart_scheduled"
+	// EventCrashed the worker panicked with Reason
+	EventCrashed EventType = "crashed"
+)
+
+// eventBufferSize the events kept for a slow reader of Events, the next ones are dropped
+const eventBufferSize = 64
+
+// Event a lifecycle event of the worker, see Events
+type Event struct {
+	Type   EventType
+	Worker string
+	Pid    int
+	At     time.Time
+	Signal os.Signal // the signal of EventSignalReceived
+	Code   int       // the exit code of EventStopped, and of the supervised worker whose exit schedules a restart
+	Reason string    // why the restart of EventRestartScheduled happens, the panic of EventCrashed
+}
+
+// events the channel of Events, created by its first call
+type events struct {
+	sync.Mutex
+	ch chan Event
+}
+
+// Events the lifecycle events of the worker in this process, for dashboards, metrics or tests without parsing logs.
+// they are emitted in the child, and by the supervisor of WithSupervision for the restarts it schedules.
+// an event is dropped when the channel is full, and the events right before the process exits or executes itself
+// again on restart may not be read
+func (process *Process) Events() <-chan Event {
+	process.events.Lock()
+	defer process.events.Unlock()
+	if process.events.ch == nil {
+		process.events.ch = make(chan Event, eventBufferSize)
+	}
+	return process.events.ch
+}
+
+// publish send event to Events, if it was called
+func (process *Process) publish(event Event) {
+	process.events.Lock()
+	ch := process.events.ch
+	process.events.Unlock()
+	if ch == nil {
+		return
+	}
+	event.Worker, event.Pid, event.At = process.worker.Name(), os.Getpid(), time.Now()
+	select {
+	case ch <- event:
+	default:
+	}
+}
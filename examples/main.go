@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"syscall"
 
 	"github.com/kenretto/daemon"
@@ -44,7 +43,13 @@ func (httpServer *HTTPServer) Start() {
 		_, _ = writer.Write([]byte("hello world"))
 	})
 	httpServer.http = &http.Server{Handler: http.DefaultServeMux, Addr: ":9047"}
-	_ = httpServer.http.ListenAndServe()
+	// daemon.Listen hands the listener to the new child on restart, no connection is refused in between
+	listener, err := daemon.Listen("tcp", httpServer.http.Addr)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	_ = httpServer.http.Serve(listener)
 }
 
 // Stop stop web server
@@ -62,20 +67,20 @@ func (httpServer *HTTPServer) Restart() error {
 	return err
 }
 
-func main() {
-	// Custom output file
-	out, _ := os.OpenFile("./http.log", os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
-	err, _ := os.OpenFile("./http_err.log", os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+// set at build time with -ldflags "-X main.version=v1.0.0 -X main.commit=... -X main.date=..."
+var version, commit, date = "dev", "", ""
 
-	// Initialize a new running program
-	proc := daemon.NewProcess(new(HTTPServer)).SetPipeline(nil, out, err)
+func main() {
+	daemon.SetVersion(version, commit, date)
+	// Initialize a new running program, its output goes to the log files
+	proc := daemon.NewProcess(new(HTTPServer)).SetLogFiles("./http.log", "./http_err.log")
 	proc.On(syscall.SIGTERM, func() {
 		fmt.Println("a custom signal")
 	})
 	// example: multi-level command service.
-	// because the Command interface is implemented in the example here, there will be a situation where flag test does not exist. In fact, each worker should be unique.
-	// do not share a worker object pointer
-	daemon.GetCommand().AddWorker(proc).AddWorker(proc)
+	// every node needs a worker of its own, the factory creates one per node
+	newServer := func() daemon.Worker { return new(HTTPServer) }
+	daemon.GetCommand().AddWorkerFactory(newServer).AddWorkerFactory(newServer)
 	// example: register main service
 	daemon.Register(proc)
 
@@ -0,0 +1,107 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// flagsEnv name of the environment variable that carries the parsed flags to the child
+func (process *Process) flagsEnv() string {
+	return process.daemonTag + "_FLAGS"
+}
+
+// setCommand remember cmd and hand it to the worker if it implements Command.
+// the same worker may be attached to several nodes, all of them receive the flags in the child
+func (process *Process) setCommand(cmd *cobra.Command) {
+	process.commands = append(process.commands, cmd)
+	if worker, ok := process.impl.(Command); ok {
+		worker.SetCommand(cmd)
+	}
+}
+
+// captureFlags serialize the flags changed on the command line of cmd, they are handed to the child by Run.
+// slice flags keep their elements, the others are a single value
+func (process *Process) captureFlags(cmd *cobra.Command) {
+	process.flags = make(map[string][]string)
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			process.flags[flag.Name] = slice.GetSlice()
+			return
+		}
+		process.flags[flag.Name] = []string{flag.Value.String()}
+	})
+}
+
+// flag the serialized value of the flag name, empty when it was not changed
+func (process *Process) flag(name string) string {
+	if values := process.flags[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// restoreFlags apply the flags serialized by the parent to every command handed to the worker,
+// so the worker reads the same values no matter which command object it saved.
+// flags already set on the command line of the child win
+func (process *Process) restoreFlags() error {
+	if data := os.Getenv(process.flagsEnv()); data != "" {
+		var flags map[string][]string
+		if err := json.Unmarshal([]byte(data), &flags); err != nil {
+			return fmt.Errorf("decode %s: %v", process.flagsEnv(), err)
+		}
+		process.flags = flags
+	}
+
+	for _, cmd := range process.commands {
+		// merge the persistent flags, otherwise they are only visible on the command that was executed
+		cmd.LocalFlags()
+		for name, values := range process.flags {
+			if err := restoreFlag(cmd.Flags(), name, values); err != nil {
+				return fmt.Errorf("restore flag %s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// restoreFlag set the flag name of flags to values, unless it does not exist or is already changed
+func restoreFlag(flags *pflag.FlagSet, name string, values []string) error {
+	flag := flags.Lookup(name)
+	if flag == nil || flag.Changed {
+		return nil
+	}
+	slice, ok := flag.Value.(pflag.SliceValue)
+	if !ok {
+		if len(values) == 0 {
+			return nil
+		}
+		return flags.Set(name, values[0])
+	}
+	if err := slice.Replace(values); err != nil {
+		return err
+	}
+	flag.Changed = true
+	return nil
+}
+
+// flagsEnviron the environment entry to append to the child environment
+func (process *Process) flagsEnviron() string {
+	data, _ := json.Marshal(process.flags)
+	return fmt.Sprintf("%s=%s", process.flagsEnv(), data)
+}
+
+// captureArgs keep everything after "--" on the command line, it is delivered to the worker untouched
+func (process *Process) captureArgs(cmd *cobra.Command, args []string) {
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		process.args = args[dash:]
+	}
+}
+
+// Args the arguments passed after "--", such as `app http start -- --port 9090` gives [--port 9090]
+func (process *Process) Args() []string {
+	return process.args
+}
@@ -0,0 +1,76 @@
+package daemon
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// flagsCommand a command with a flag of every kind
+func flagsCommand() *cobra.Command {
+	cmd := &cobra.Command{Use: "app"}
+	cmd.Flags().String("name", "default", "")
+	cmd.Flags().Int("port", 80, "")
+	cmd.Flags().Bool("debug", false, "")
+	cmd.Flags().StringSlice("tags", []string{"x"}, "")
+	cmd.Flags().IntSlice("ids", nil, "")
+	cmd.Flags().StringArray("header", nil, "")
+	cmd.PersistentFlags().String("region", "", "")
+	return cmd
+}
+
+func TestRestoreFlags(t *testing.T) {
+	tests := []struct {
+		name   string
+		parent []string // command line of the start command
+		child  []string // command line of the child
+		want   map[string]string
+	}{
+		{"scalars", []string{"--name", "api", "--port=8080", "--debug"}, nil,
+			map[string]string{"name": "api", "port": "8080", "debug": "true"}},
+		{"defaults kept", nil, nil, map[string]string{"name": "default", "port": "80", "tags": "[x]"}},
+		{"string slice", []string{"--tags", "a,b", "--tags", "c"}, nil, map[string]string{"tags": "[a,b,c]"}},
+		{"comma in array", []string{"--header", "Accept: a, b", "--header", "X: 1"}, nil,
+			map[string]string{"header": `["Accept: a, b",X: 1]`}},
+		{"int slice", []string{"--ids", "1,2", "--ids=3"}, nil, map[string]string{"ids": "[1,2,3]"}},
+		{"persistent", []string{"--region", "eu"}, nil, map[string]string{"region": "eu"}},
+		{"child wins", []string{"--name", "api", "--tags", "a"}, []string{"--name", "worker"},
+			map[string]string{"name": "worker", "tags": "[a]"}},
+		{"child slice wins", []string{"--tags", "a,b"}, []string{"--tags", "c"}, map[string]string{"tags": "[c]"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parent := flagsCommand()
+			if err := parent.ParseFlags(test.parent); err != nil {
+				t.Fatal(err)
+			}
+			process := NewProcess(testWorker{dir: "/var/run", name: "flags"})
+			process.captureFlags(parent)
+			environ := strings.SplitN(process.flagsEnviron(), "=", 2)
+			_ = os.Setenv(environ[0], environ[1])
+			defer os.Unsetenv(environ[0])
+
+			// the child parses its own command line, then receives the flags of the parent
+			child := flagsCommand()
+			if err := child.ParseFlags(test.child); err != nil {
+				t.Fatal(err)
+			}
+			process = NewProcess(testWorker{dir: "/var/run", name: "flags"})
+			process.setCommand(child)
+			if err := process.restoreFlags(); err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(map[string]string)
+			for name := range test.want {
+				got[name] = child.Flags().Lookup(name).Value.String()
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("restored %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package daemon
 
 import (
+	"errors"
+	"fmt"
 	"os"
-	"path"
+	"path/filepath"
+	"syscall"
 )
 
 // lock a file
@@ -11,9 +14,9 @@ func lock(file *os.File) error {
 	return err
 }
 
-// write file
-func write(filename string, body string) (file *os.File, err error) {
-	file, err = create(filename)
+// write file, missing directories are created with dirMode
+func write(filename string, body string, dirMode os.FileMode) (file *os.File, err error) {
+	file, err = create(filename, dirMode)
 	if err != nil {
 		return
 	}
@@ -22,13 +25,13 @@ func write(filename string, body string) (file *os.File, err error) {
 	return
 }
 
-// create file
-func create(filename string) (file *os.File, err error) {
-	dir := path.Dir(filename)
+// create file, missing directories are created with dirMode
+func create(filename string, dirMode os.FileMode) (file *os.File, err error) {
+	dir := filepath.Dir(filename)
 	_, err = os.Stat(dir)
 	if err != nil {
 		if os.IsNotExist(err) {
-			err = os.MkdirAll(dir, 0644)
+			err = os.MkdirAll(dir, dirMode)
 			if err != nil {
 				return
 			}
@@ -37,7 +40,14 @@ func create(filename string) (file *os.File, err error) {
 		}
 	}
 	file, err = os.OpenFile(filename, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return
+	}
 	if err = lock(file); err != nil {
+		_ = file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) || errors.Is(err, syscall.EAGAIN) {
+			err = fmt.Errorf("%s: %w", filename, ErrAlreadyRunning)
+		}
 		return
 	}
 	err = file.Truncate(0)
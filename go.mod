@@ -3,5 +3,9 @@ module github.com/kenretto/daemon
 go 1.13
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/spf13/cobra v0.0.5
+	github.com/spf13/pflag v1.0.5
+	golang.org/x/sys v0.0.0-20201119102817-f84b799fce68
+	gopkg.in/yaml.v2 v2.4.0
 )
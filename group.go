@@ -0,0 +1,302 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// DependsOn declare that the worker of daemon needs the worker called name, the group commands start name first and stop it last
+func (daemon *Daemon) DependsOn(name string) *Daemon {
+	daemon.dependencies = append(daemon.dependencies, name)
+	return daemon
+}
+
+// nodes the daemons with a worker below daemon, daemon first and then in registration order
+func (daemon *Daemon) nodes() []*Daemon {
+	var nodes []*Daemon
+	if daemon.worker != nil {
+		nodes = append(nodes, daemon)
+	}
+	for _, child := range daemon.order {
+		nodes = append(nodes, child.nodes()...)
+	}
+	return nodes
+}
+
+// path the arguments that select the commands of daemon, without the binary name
+func (daemon *Daemon) path() []string {
+	return strings.Fields(daemon.command.CommandPath())[1:]
+}
+
+// ordered the workers below daemon sorted so that every worker comes after its dependencies,
+// workers without dependencies between them keep the registration order
+func (daemon *Daemon) ordered() ([]*Daemon, error) {
+	nodes := daemon.nodes()
+	byName := make(map[string]*Daemon, len(nodes))
+	for _, node := range nodes {
+		byName[node.worker.worker.Name()] = node
+	}
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+	marks := make(map[*Daemon]int, len(nodes))
+	ordered := make([]*Daemon, 0, len(nodes))
+	var visit func(node *Daemon) error
+	visit = func(node *Daemon) error {
+		switch marks[node] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle through %s", node.worker.worker.Name())
+		}
+		marks[node] = visiting
+		for _, name := range node.dependencies {
+			dependency, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("%s depends on %s, which is not registered", node.worker.worker.Name(), name)
+			}
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		marks[node] = visited
+		ordered = append(ordered, node)
+		return nil
+	}
+
+	for _, node := range nodes {
+		if err := visit(node); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// waitStarted wait until worker saved the pid file of a live process
+func waitStarted(worker *Process, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if state, err := worker.State(); err == nil && state.Running {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(waitInterval)
+	}
+}
+
+// startAll start every worker in dependency order, each one has to be running before the workers that depend on it are started
+func (daemon *Daemon) startAll(cmd *cobra.Command, timeout time.Duration) error {
+	nodes, err := daemon.ordered()
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		path := node.command.CommandPath()
+		if state, err := node.worker.State(); err == nil && state.Running {
+			report(cmd, stateResult(StartCommand, state), "%s: already running\n", path)
+			continue
+		}
+		start := node.Command(StartCommand)
+		if start == nil {
+			report(cmd, node.worker.result(StartCommand, StateNotRunning), "%s: no start command, skipped\n", path)
+			continue
+		}
+
+		starting := exec.Command(executable(), append(node.path(), start.Name())...)
+		starting.Stdin, starting.Stdout, starting.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if jsonOutput(cmd) {
+			// only the results of the group are printed
+			starting.Stdout = nil
+		}
+		if err = starting.Run(); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if !waitStarted(node.worker, timeout) {
+			return fmt.Errorf("%s: not running after %s", path, timeout)
+		}
+		state, _ := node.worker.State()
+		result := stateResult(StartCommand, state)
+		result.State = StateStarted
+		report(cmd, result, "%s: started\n", path)
+	}
+	return nil
+}
+
+// stopAll stop every worker in reverse dependency order, returns false if any of them did not stop within timeout
+func (daemon *Daemon) stopAll(cmd *cobra.Command, timeout time.Duration) (bool, error) {
+	nodes, err := daemon.ordered()
+	if err != nil {
+		return false, err
+	}
+	ok := true
+	for i := len(nodes) - 1; i >= 0; i-- {
+		node := nodes[i]
+		if !node.worker.confirm(cmd, "stop") {
+			ok = false
+			continue
+		}
+		if !stopWorker(cmd, node.worker, node.command.CommandPath(), timeout) {
+			ok = false
+		}
+	}
+	return ok, nil
+}
+
+// statusAll print the state of every worker, returns the LSB exit code: 0 when all of them run
+func (daemon *Daemon) statusAll(cmd *cobra.Command) (int, error) {
+	nodes, err := daemon.ordered()
+	if err != nil {
+		return 0, err
+	}
+	code := 0
+	for _, node := range nodes {
+		path := node.command.CommandPath()
+		state, err := node.worker.State()
+		result := stateResult(StatusCommand, state)
+		switch {
+		case err != nil && os.IsNotExist(err):
+			report(cmd, result, "%s: not running\n", path)
+			if code == 0 {
+				code = ExitNotRunning
+			}
+		case err != nil:
+			result.Error = err.Error()
+			report(cmd, result, "%s: %v\n", path, err)
+			code = ExitDead
+		case !state.Running:
+			report(cmd, result, "%s: dead, pid file %s exists (pid %d)\n", path, state.PidFile, state.Pid)
+			code = ExitDead
+		default:
+			report(cmd, result, "%s: running (pid %d, uptime %s)\n", path, state.Pid, state.Uptime())
+		}
+	}
+	return code, nil
+}
+
+// groupCommands the start, stop and status commands operating on every registered worker, called "all"
+func (daemon *Daemon) groupCommands() map[string]*cobra.Command {
+	start := &cobra.Command{
+		Use:   "all",
+		Short: "start every worker in dependency order",
+		Args:  cobra.NoArgs,
+		RunE: runE(func(cmd *cobra.Command, args []string) error {
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+			if err := daemon.startAll(cmd, timeout); err != nil {
+				return failed(Result{Command: StartCommand}, err, 1)
+			}
+			return nil
+		}),
+	}
+	start.Flags().Duration("timeout", DefaultStopTimeout+waitGrace, "how long to wait for each worker to be running")
+
+	stop := &cobra.Command{
+		Use:   "all",
+		Short: "stop every worker in reverse dependency order",
+		Args:  cobra.NoArgs,
+		RunE: runE(func(cmd *cobra.Command, args []string) error {
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+			ok, err := daemon.stopAll(cmd, timeout)
+			if err != nil {
+				return failed(Result{Command: StopCommand}, err, 1)
+			}
+			if !ok {
+				return exitWith(1)
+			}
+			return nil
+		}),
+	}
+	addConfirmFlag(stop)
+	stop.Flags().Duration("timeout", DefaultStopTimeout+waitGrace, "how long to wait for each worker to exit")
+
+	status := &cobra.Command{
+		Use:   "all",
+		Short: "show whether every worker is running",
+		Args:  cobra.NoArgs,
+		RunE: runE(func(cmd *cobra.Command, args []string) error {
+			code, err := daemon.statusAll(cmd)
+			if err != nil {
+				return failed(Result{Command: StatusCommand}, err, 1)
+			}
+			if code != 0 {
+				return exitWith(code)
+			}
+			return nil
+		}),
+	}
+
+	return map[string]*cobra.Command{StartCommand: start, StopCommand: stop, StatusCommand: status}
+}
+
+// addGroupCommands add the group commands to the root: "start all", "stop all" and "status all" below the commands of the
+// main worker, and plain start, stop and status when there is no main worker
+func (daemon *Daemon) addGroupCommands() {
+	if len(daemon.order) == 0 {
+		return
+	}
+	roots := daemon.groupCommands()
+	for verb, all := range daemon.groupCommands() {
+		parent := daemon.Command(verb)
+		if parent == nil {
+			parent = roots[verb]
+			parent.Use = verb
+			daemon.command.AddCommand(parent)
+		}
+		parent.AddCommand(all)
+	}
+}
+
+// list print every registered worker and its state
+func (daemon *Daemon) list(cmd *cobra.Command) {
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if !jsonOutput(cmd) {
+		fmt.Fprintln(table, "NAME\tPID\tUPTIME\tSTATE")
+	}
+	for _, node := range daemon.nodes() {
+		state, _ := node.worker.State()
+		result := stateResult("list", state)
+		if jsonOutput(cmd) {
+			report(cmd, result, "")
+			continue
+		}
+
+		name := strings.Join(node.path(), " ")
+		if name == "" {
+			name = daemon.command.Name()
+		}
+		pid, uptime := "-", "-"
+		if result.State != StateNotRunning {
+			pid = strconv.Itoa(state.Pid)
+		}
+		if state.Running {
+			uptime = state.Uptime().String()
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", name, pid, uptime, result.State)
+	}
+	_ = table.Flush()
+}
+
+// addListCommand add the list command to the root
+func (daemon *Daemon) addListCommand() {
+	if len(daemon.nodes()) == 0 {
+		return
+	}
+	daemon.command.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "list the registered workers and their state",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			daemon.list(cmd)
+		},
+	})
+}
@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestOrdered(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers []string            // in registration order
+		depends map[string][]string // dependencies of the workers
+		want    []string
+		err     string
+	}{
+		{"registration order", []string{"a", "b", "c"}, nil, []string{"a", "b", "c"}, ""},
+		{"dependency first", []string{"web", "db"}, map[string][]string{"web": {"db"}}, []string{"db", "web"}, ""},
+		{"chain", []string{"a", "b", "c"}, map[string][]string{"a": {"b"}, "b": {"c"}}, []string{"c", "b", "a"}, ""},
+		{"shared dependency", []string{"api", "web", "db"}, map[string][]string{"api": {"db"}, "web": {"db"}},
+			[]string{"db", "api", "web"}, ""},
+		{"several dependencies", []string{"web", "cache", "db"}, map[string][]string{"web": {"db", "cache"}},
+			[]string{"db", "cache", "web"}, ""},
+		{"unknown dependency", []string{"web"}, map[string][]string{"web": {"db"}}, nil, "web depends on db, which is not registered"},
+		{"cycle", []string{"a", "b"}, map[string][]string{"a": {"b"}, "b": {"a"}}, nil, "dependency cycle"},
+		{"longer cycle", []string{"a", "b", "c"}, map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}}, nil, "dependency cycle"},
+		{"depends on itself", []string{"a"}, map[string][]string{"a": {"a"}}, nil, "dependency cycle through a"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := &Daemon{command: &cobra.Command{Use: "app"}}
+			for _, name := range test.workers {
+				node := root.AddWorker(NewProcess(testWorker{dir: "/var/run", name: name}))
+				for _, dependency := range test.depends[name] {
+					node.DependsOn(dependency)
+				}
+			}
+
+			ordered, err := root.ordered()
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("ordered() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, node := range ordered {
+				names = append(names, node.worker.worker.Name())
+			}
+			if !reflect.DeepEqual(names, test.want) {
+				t.Errorf("ordered() = %v, want %v", names, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package grpccontrol
+
+import (
+	"context"
+	"crypto/tls"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Dial connect to the Control service served on address, "unix:path" or a TCP address with config holding the
+// client certificate and the CA of the server, see WithToken for the other options. close the connection once done
+func Dial(address string, config *tls.Config, options ...grpc.DialOption) (ControlClient, *grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if config != nil {
+		creds = credentials.NewTLS(config)
+	}
+	options = append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, options...)
+	conn, err := grpc.NewClient(address, options...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewControlClient(conn), conn, nil
+}
+
+// WithToken send token with every request, for the Token of the daemon.ControlAuth of the process
+func WithToken(token string) grpc.DialOption {
+	return grpc.WithPerRPCCredentials(tokenCredentials(token))
+}
+
+// tokenCredentials the token sent as "authorization: Bearer <token>"
+type tokenCredentials string
+
+func (token tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(token)}, nil
+}
+
+// RequireTransportSecurity the token may also be sent over a unix socket
+func (token tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
@@ -0,0 +1,23 @@
+module github.com/kenretto/daemon/grpccontrol
+
+go 1.21
+
+require (
+	github.com/kenretto/daemon v0.0.0-20261015134825-cf0ce8d8249c
+	google.golang.org/grpc v1.64.1
+	google.golang.org/protobuf v1.36.0
+)
+
+require (
+	github.com/BurntSushi/toml v0.3.1 // indirect
+	github.com/inconshreveable/mousetrap v1.0.0 // indirect
+	github.com/spf13/cobra v0.0.5 // indirect
+	github.com/spf13/pflag v1.0.5 // indirect
+	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+)
+
+replace github.com/kenretto/daemon => ../
@@ -0,0 +1,217 @@
+// Package grpccontrol serves the Control gRPC service of control.proto from the child of a daemon, so that fleet
+// management tools can stop, restart, reload and query it remotely, over a unix socket or TCP with mutual TLS
+package grpccontrol
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative control.proto
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/kenretto/daemon"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// stopGrace how long the server lets the requests in flight finish when the worker stops
+const stopGrace = time.Second
+
+var (
+	// errPlainTCP the service is only served over TCP with TLS
+	errPlainTCP = errors.New("grpccontrol: serving over TCP needs a TLS config")
+	// errClientAuth over TCP, the clients have to be authenticated by their certificates
+	errClientAuth = errors.New("grpccontrol: serving over TCP needs ClientAuth tls.RequireAndVerifyClientCert and ClientCAs")
+)
+
+// Config where and how the Control service is served
+type Config struct {
+	// Address "unix:path" for a unix socket, a TCP address such as ":9443" otherwise
+	Address string
+	// TLS required over TCP, with ClientAuth tls.RequireAndVerifyClientCert and ClientCAs for mutual TLS,
+	// other configs are refused
+	TLS *tls.Config
+}
+
+// Enable serve the Control service from the child of process while its worker runs. the listener is handed
+// to the new child on restart, see daemon.Listen
+func Enable(process *daemon.Process, config Config) *daemon.Process {
+	server := &server{process: process, config: config}
+	return process.AddHooks(daemon.Hooks{PreStart: server.serve, PreStop: server.stop})
+}
+
+// server the Control service of one process
+type server struct {
+	UnimplementedControlServer
+	process *daemon.Process
+	config  Config
+	grpc    *grpc.Server
+}
+
+// serve listen on the address of the config and serve the requests
+func (server *server) serve() error {
+	listener, err := server.listen()
+	if err != nil {
+		return err
+	}
+	options := []grpc.ServerOption{grpc.UnaryInterceptor(server.authorize)}
+	if server.config.TLS != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(server.config.TLS)))
+	}
+	server.grpc = grpc.NewServer(options...)
+	RegisterControlServer(server.grpc, server)
+	go func() {
+		_ = server.grpc.Serve(listener)
+	}()
+	return nil
+}
+
+// listen the listener of the address of the config
+func (server *server) listen() (net.Listener, error) {
+	path := strings.TrimPrefix(server.config.Address, "unix:")
+	if path == server.config.Address {
+		if server.config.TLS == nil {
+			return nil, errPlainTCP
+		}
+		if server.config.TLS.ClientAuth != tls.RequireAndVerifyClientCert || server.config.TLS.ClientCAs == nil {
+			return nil, errClientAuth
+		}
+		return daemon.Listen("tcp", server.config.Address)
+	}
+	listener, err := daemon.Listen("unix", path)
+	if err != nil {
+		// the socket left by a process that did not remove it, nobody answers on it
+		if conn, dialErr := net.Dial("unix", path); dialErr == nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		_ = os.Remove(path)
+		listener, err = daemon.Listen("unix", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// like the control socket of the daemon, other users reach it only when the ControlAuth allows their UIDs,
+	// which are checked by their peer credentials, read on Linux only
+	mode := os.FileMode(0600)
+	if runtime.GOOS == "linux" && len(server.process.ControlAuth().UIDs) > 0 {
+		mode = 0666
+	}
+	if err = os.Chmod(path, mode); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return &peerListener{Listener: listener, process: server.process}, nil
+}
+
+// authorize check the token of the daemon.ControlAuth of the process, sent as "authorization: Bearer <token>"
+func (server *server) authorize(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			token = strings.TrimPrefix(values[0], "Bearer ")
+		}
+	}
+	if err := server.process.AuthorizeToken(token); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return handler(ctx, request)
+}
+
+// peerListener close the connections of the users the daemon.ControlAuth of the process does not allow
+type peerListener struct {
+	net.Listener
+	process *daemon.Process
+}
+
+func (listener *peerListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := listener.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if listener.process.AuthorizePeer(conn) == nil {
+			return conn, nil
+		}
+		_ = conn.Close()
+	}
+}
+
+// stop let the requests in flight finish, such as the Stop request that stops the worker
+func (server *server) stop() {
+	if server.grpc == nil {
+		return
+	}
+	stopped := make(chan struct{})
+	go func() {
+		server.grpc.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(stopGrace):
+		server.grpc.Stop()
+	}
+}
+
+// reply run the control request command
+func (server *server) reply(command string) (*Reply, error) {
+	message, err := server.process.Control(command)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &Reply{Message: message}, nil
+}
+
+// Start the worker is running when its child answers, there is nothing to start
+func (server *server) Start(ctx context.Context, request *StartRequest) (*StatusReply, error) {
+	reply, err := server.Status(ctx, &StatusRequest{})
+	if reply != nil {
+		reply.Message = "already running"
+	}
+	return reply, err
+}
+
+// Stop stop the worker gracefully
+func (server *server) Stop(ctx context.Context, request *StopRequest) (*Reply, error) {
+	return server.reply(daemon.ControlStop)
+}
+
+// Restart restart the worker
+func (server *server) Restart(ctx context.Context, request *RestartRequest) (*Reply, error) {
+	return server.reply(daemon.ControlRestart)
+}
+
+// Reload reload the worker
+func (server *server) Reload(ctx context.Context, request *ReloadRequest) (*Reply, error) {
+	return server.reply(daemon.ControlReload)
+}
+
+// Status the state of the worker
+func (server *server) Status(ctx context.Context, request *StatusRequest) (*StatusReply, error) {
+	state, err := server.process.State()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	stats, metrics := server.process.Stats(), server.process.Metrics()
+	return &StatusReply{
+		Worker:        state.Name,
+		Running:       state.Running,
+		Pid:           int64(state.Pid),
+		StartedUnix:   stats.Started.Unix(),
+		UptimeSeconds: stats.Uptime.Seconds(),
+		Restarts:      int64(stats.Restarts),
+		RestartReason: stats.RestartReason,
+		Ready:         metrics.Ready,
+		Healthy:       metrics.Healthy,
+		Version:       daemon.Version().Version,
+	}, nil
+}
@@ -0,0 +1,80 @@
+package grpccontrol
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/kenretto/daemon"
+)
+
+// testWorker a worker that does nothing
+type testWorker struct{ dir string }
+
+func (worker testWorker) PidSavePath() string { return worker.dir }
+func (worker testWorker) Name() string        { return "grpc" }
+func (worker testWorker) Start()              {}
+func (worker testWorker) Stop() error         { return nil }
+func (worker testWorker) Restart() error      { return nil }
+
+func TestListen(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets have no mode on windows")
+	}
+	dir, err := ioutil.TempDir("", "grpccontrol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// other users are allowed where their peer credentials are checked
+	allowed := os.FileMode(0600)
+	if runtime.GOOS == "linux" {
+		allowed = 0666
+	}
+	tests := []struct {
+		name    string
+		address string
+		tls     *tls.Config
+		auth    daemon.ControlAuth
+		mode    os.FileMode // of the socket
+		err     error
+	}{
+		{"owner only", "unix:" + filepath.Join(dir, "owner.sock"), nil, daemon.ControlAuth{}, 0600, nil},
+		{"token", "unix:" + filepath.Join(dir, "token.sock"), nil, daemon.ControlAuth{Token: "secret"}, 0600, nil},
+		{"allowed uids", "unix:" + filepath.Join(dir, "uids.sock"), nil, daemon.ControlAuth{UIDs: []int{1000}}, allowed, nil},
+		{"plain tcp", "127.0.0.1:0", nil, daemon.ControlAuth{}, 0, errPlainTCP},
+		{"tcp without client certificates", "127.0.0.1:0", &tls.Config{}, daemon.ControlAuth{}, 0, errClientAuth},
+		{"tcp without client CAs", "127.0.0.1:0", &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert}, daemon.ControlAuth{}, 0, errClientAuth},
+		{"tcp with mutual tls", "127.0.0.1:0", &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: x509.NewCertPool()},
+			daemon.ControlAuth{}, 0, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			process := daemon.NewProcess(testWorker{dir: dir}).SetControlAuth(test.auth)
+			server := &server{process: process, config: Config{Address: test.address, TLS: test.tls}}
+			listener, err := server.listen()
+			if err != test.err {
+				t.Fatalf("listen() error = %v, want %v", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+			defer listener.Close()
+			if test.mode == 0 {
+				return
+			}
+			info, err := os.Stat(filepath.Join(dir, filepath.Base(test.address)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mode := info.Mode().Perm(); mode != test.mode {
+				t.Errorf("socket mode %o, want %o", mode, test.mode)
+			}
+		})
+	}
+}
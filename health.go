@@ -0,0 +1,77 @@
+package daemon
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// HealthChecker If the worker implements this interface, Healthy is polled once it is ready,
+// the worker is restarted after HealthCheck.Failures consecutive failures
+type HealthChecker interface {
+	Healthy() error
+}
+
+// HealthCheck how the health of a HealthChecker is polled
+type HealthCheck struct {
+	Interval time.Duration // between two checks
+	Timeout  time.Duration // a check that takes longer failed
+	Failures int           // consecutive failures that restart the worker
+}
+
+// DefaultHealthCheck the health check of a HealthChecker unless SetHealthCheck is used
+var DefaultHealthCheck = HealthCheck{Interval: 10 * time.Second, Timeout: 5 * time.Second, Failures: 3}
+
+// SetHealthCheck configure how the health of a HealthChecker is polled
+func (process *Process) SetHealthCheck(check HealthCheck) *Process {
+	return process.configure("SetHealthCheck", func() {
+		process.healthCheck = check
+	})
+}
+
+// healthy run the check of checker, bounded by the timeout
+func (check HealthCheck) healthy(checker HealthChecker) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- checker.Healthy()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(check.Timeout):
+		return fmt.Errorf("health check %w after %s", ErrTimeout, check.Timeout)
+	}
+}
+
+// probeHealth poll the health of the worker, and restart it through the restart handler when it keeps failing
+func (process *Process) probeHealth() {
+	checker, ok := process.impl.(HealthChecker)
+	check := process.healthCheck
+	if !ok || check.Interval <= 0 {
+		return
+	}
+
+	failures := 0
+	for range time.Tick(check.Interval) {
+		err := check.healthy(checker)
+		if err == nil {
+			failures = 0
+			if atomic.SwapInt32(&process.unhealthy, 0) == 1 {
+				process.writeStatus()
+			}
+			continue
+		}
+		failures++
+		if atomic.SwapInt32(&process.unhealthy, 1) == 0 {
+			process.writeStatus()
+		}
+		process.error("health check failed", "err", err, "failures", failures)
+		if failures >= check.Failures {
+			process.error("worker unhealthy, restarting", "failures", failures)
+			if err = process.requestRestart("health check failed"); err != nil {
+				process.error("restart failed", "err", err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package daemon
+
+// Hooks callbacks run in the child around the lifecycle of the worker, for warmup, cache flushes or notifications
+// without wrapping the Worker. unset ones are skipped
+type Hooks struct {
+	PreStart   func() error   // before worker.Start, once the pid file is saved, an error fails the start
+	PostStart  func()         // once worker.Start runs
+	PreStop    func()         // before worker.Stop
+	PostStop   func()         // after worker.Stop returned or timed out
+	PreRestart func()         // before the new child is started, or the binary executed again in the foreground
+	OnExit     func(code int) // right before the child exits with code, after a stop, a crash or a completed worker
+}
+
+// AddHooks register hooks, the hooks of every call run in registration order
+func (process *Process) AddHooks(hooks Hooks) *Process {
+	return process.configure("AddHooks", func() {
+		process.hooks = append(process.hooks, hooks)
+	})
+}
+
+// hookPreStart run the PreStart hooks, until one fails
+func (process *Process) hookPreStart() error {
+	for _, hooks := range process.hooks {
+		if hooks.PreStart != nil {
+			if err := hooks.PreStart(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hookPostStart run the PostStart hooks
+func (process *Process) hookPostStart() {
+	for _, hooks := range process.hooks {
+		if hooks.PostStart != nil {
+			hooks.PostStart()
+		}
+	}
+}
+
+// hookPreStop run the PreStop hooks
+func (process *Process) hookPreStop() {
+	for _, hooks := range process.hooks {
+		if hooks.PreStop != nil {
+			hooks.PreStop()
+		}
+	}
+}
+
+// hookPostStop run the PostStop hooks
+func (process *Process) hookPostStop() {
+	for _, hooks := range process.hooks {
+		if hooks.PostStop != nil {
+			hooks.PostStop()
+		}
+	}
+}
+
+// hookPreRestart run the PreRestart hooks
+func (process *Process) hookPreRestart() {
+	for _, hooks := range process.hooks {
+		if hooks.PreRestart != nil {
+			hooks.PreRestart()
+		}
+	}
+}
+
+// hookExit run the OnExit hooks
+func (process *Process) hookExit(code int) {
+	for _, hooks := range process.hooks {
+		if hooks.OnExit != nil {
+			hooks.OnExit(code)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package daemon
+
+import "os"
+
+// asInit set by RunAsInit
+var asInit bool
+
+// RunAsInit make the binary safe as the entrypoint of a container: start runs the worker in the foreground without
+// forking, the orphans of its processes are reaped, SIGTERM stops it gracefully and the signals without handler are
+// forwarded to the processes it started. enabled automatically when the binary runs as pid 1
+func RunAsInit() {
+	asInit = true
+}
+
+// runningAsInit whether the binary runs as the init process of a container
+func runningAsInit() bool {
+	return asInit || os.Getpid() == 1
+}
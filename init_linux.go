@@ -0,0 +1,33 @@
+package daemon
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// forwardedSignals the signals passed on to the processes of the worker by the init process, unless they have a handler
+var forwardedSignals = []os.Signal{syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGWINCH,
+	syscall.SIGALRM, syscall.SIGCONT}
+
+// forwardSignals as the init process, forward the signals without handler to the children of this process
+func (process *Process) forwardSignals() {
+	if !process.asInit {
+		return
+	}
+	received := make(chan os.Signal, signalBuffer)
+	signal.Notify(received, forwardedSignals...)
+	go func() {
+		for sig := range received {
+			if process.signals.registered(sig) {
+				continue
+			}
+			self := os.Getpid()
+			for pid, stat := range processes() {
+				if stat.parent == self {
+					_ = signalPid(pid, sig)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+// +build !linux
+
+package daemon
+
+// forwardSignals containers run Linux
+func (process *Process) forwardSignals() {}
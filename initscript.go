@@ -0,0 +1,196 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// initScriptPath directory of the generated OpenRC and SysV init scripts
+var initScriptPath = "/etc/init.d"
+
+// the init scripts run the lifecycle commands, which daemonize the worker, write the pid file and report the
+// status with the LSB exit codes: 0 running, 1 dead with a pid file, 3 not running
+var scriptFuncs = template.FuncMap{"sh": shellCommandLine}
+
+var openrcScript = template.Must(template.New("openrc").Funcs(scriptFuncs).Parse(`#!/sbin/openrc-run
+
+description="{{.Name}}"
+pidfile={{sh .PidFile}}
+extra_started_commands="reload"
+
+depend() {
+	need net
+	use logger
+}
+
+start() {
+	ebegin "Starting ${RC_SVCNAME}"
+	{{sh .Executable}} {{sh .StartArgs}}
+	eend $?
+}
+
+stop() {
+	ebegin "Stopping ${RC_SVCNAME}"
+	{{sh .Executable}} {{sh .StopArgs}}
+	eend $?
+}
+
+reload() {
+	ebegin "Restarting ${RC_SVCNAME}"
+	{{sh .Executable}} {{sh .RestartArgs}}
+	eend $?
+}
+
+status() {
+	{{sh .Executable}} {{sh .StatusArgs}}
+}
+`))
+
+var sysvScript = template.Must(template.New("sysv").Funcs(scriptFuncs).Parse(`#!/bin/sh
+#
+# chkconfig: 2345 90 10
+# description: {{.Name}}
+# pidfile: {{.PidFile}}
+#
+### BEGIN INIT INFO
+# Provides:          {{.Name}}
+# Required-Start:    $remote_fs $network $syslog
+# Required-Stop:     $remote_fs $network $syslog
+# Default-Start:     2 3 4 5
+# Default-Stop:      0 1 6
+# Short-Description: {{.Name}}
+### END INIT INFO
+
+case "$1" in
+start)
+	{{sh .Executable}} {{sh .StartArgs}}
+	;;
+stop)
+	{{sh .Executable}} {{sh .StopArgs}}
+	;;
+restart|force-reload)
+	{{sh .Executable}} {{sh .RestartArgs}}
+	;;
+status)
+	{{sh .Executable}} {{sh .StatusArgs}}
+	;;
+*)
+	echo "Usage: $0 {start|stop|restart|force-reload|status}" >&2
+	exit 2
+	;;
+esac
+`))
+
+// shellCommandLine quote value, a string or the arguments of a command, for a shell command line
+func shellCommandLine(value interface{}) string {
+	args, ok := value.([]string)
+	if !ok {
+		args = []string{fmt.Sprint(value)}
+	}
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=./:,+@%") == "" {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// initScript the file and rendering shared by the init script integrations
+type initScript struct {
+	script *template.Template
+}
+
+func (initScript) filename(name string) string {
+	return filepath.Join(initScriptPath, name)
+}
+
+func (system initScript) render(svc *service) (string, error) {
+	var script strings.Builder
+	err := system.script.Execute(&script, svc)
+	return script.String(), err
+}
+
+// load the init system runs the script, it must be executable
+func (system initScript) load(svc *service) error {
+	return os.Chmod(system.filename(svc.Name), 0755)
+}
+
+func (initScript) unload(name string) error {
+	return nil
+}
+
+// openrc the integration of OpenRC init scripts, such as on Alpine
+type openrc struct {
+	initScript
+}
+
+func (openrc) enable(name string, now bool) error {
+	if err := runAttached("rc-update", "add", name, "default"); err != nil {
+		return err
+	}
+	if now {
+		return runAttached("rc-service", name, "start")
+	}
+	return nil
+}
+
+func (openrc) disable(name string, now bool) error {
+	if now {
+		if err := runAttached("rc-service", name, "stop"); err != nil {
+			return err
+		}
+	}
+	return runAttached("rc-update", "del", name, "default")
+}
+
+func (openrc) hint(name string) string {
+	return fmt.Sprintf("run `rc-update add %s default && rc-service %s start` to start it", name, name)
+}
+
+// sysv the integration of SysV init scripts, registered with update-rc.d or chkconfig
+type sysv struct {
+	initScript
+}
+
+func (system sysv) enable(name string, now bool) error {
+	var err error
+	if _, lookErr := exec.LookPath("update-rc.d"); lookErr == nil {
+		err = runAttached("update-rc.d", name, "defaults")
+	} else {
+		err = runAttached("chkconfig", "--add", name)
+	}
+	if err == nil && now {
+		err = runAttached(system.filename(name), "start")
+	}
+	return err
+}
+
+func (system sysv) disable(name string, now bool) error {
+	if now {
+		if err := runAttached(system.filename(name), "stop"); err != nil {
+			return err
+		}
+	}
+	if _, err := exec.LookPath("update-rc.d"); err == nil {
+		return runAttached("update-rc.d", "-f", name, "remove")
+	}
+	return runAttached("chkconfig", "--del", name)
+}
+
+func (system sysv) hint(name string) string {
+	return fmt.Sprintf("run `%s start` to start it, enable registers it for boot", system.filename(name))
+}
+
+// runAttached run the command name with the terminal attached
+func runAttached(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,72 @@
+package daemon
+
+import (
+	"os"
+	"runtime"
+	"sync"
+)
+
+// Init an init system that can supervise the daemon
+type Init string
+
+const (
+	// InitNone no init system to integrate with, such as inside a container
+	InitNone Init = "none"
+	// InitSystemd systemd
+	InitSystemd Init = "systemd"
+	// InitOpenRC OpenRC, such as Alpine
+	InitOpenRC Init = "openrc"
+	// InitSysV SysV init scripts
+	InitSysV Init = "sysv"
+	// InitLaunchd macOS launchd
+	InitLaunchd Init = "launchd"
+	// InitSCM Windows Service Control Manager
+	InitSCM Init = "scm"
+)
+
+var (
+	initSystem     Init
+	initSystemOnce sync.Once
+)
+
+// InitSystem detect the init system of the machine, install/enable/notify features use it to pick the right integration
+func InitSystem() Init {
+	initSystemOnce.Do(func() {
+		initSystem = detectInitSystem()
+	})
+	return initSystem
+}
+
+// detectInitSystem the detection behind InitSystem
+func detectInitSystem() Init {
+	switch runtime.GOOS {
+	case "windows":
+		return InitSCM
+	case "darwin":
+		return InitLaunchd
+	}
+
+	if inContainer() {
+		return InitNone
+	}
+	switch {
+	case exists("/run/systemd/system"):
+		return InitSystemd
+	case exists("/run/openrc"), exists("/sbin/openrc-run"):
+		return InitOpenRC
+	case exists("/etc/init.d"):
+		return InitSysV
+	}
+	return InitNone
+}
+
+// inContainer whether the process runs in a docker/podman/lxc container, there is no init system to talk to
+func inContainer() bool {
+	return os.Getenv("container") != "" || exists("/.dockerenv") || exists("/run/.containerenv")
+}
+
+// exists whether filename exists
+func exists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
@@ -0,0 +1,176 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// SetInstances run n copies of the worker, prefork style: the start command spawns n children, each with its index
+// in DAEMON_INSTANCE (see Instance) and its own pid file, <name>-0.pid... stop, restart and status act on all of them.
+// start --instances overrides n, 1 runs the worker alone
+func (process *Process) SetInstances(n int) *Process {
+	return process.configure("SetInstances", func() {
+		process.instances = n
+	})
+}
+
+// instanceEnv name of the environment variable holding the index of the instance
+func (process *Process) instanceEnv() string {
+	return process.daemonTag + "_INSTANCE"
+}
+
+// Instance in the child, the index of this instance among the ones of SetInstances, -1 when the worker runs alone
+func (process *Process) Instance() int {
+	index, err := strconv.Atoi(os.Getenv(process.instanceEnv()))
+	if err != nil {
+		return -1
+	}
+	return index
+}
+
+// instanceName the name of the pid file and the other files of the instance index
+func (process *Process) instanceName(index int) string {
+	return fmt.Sprintf("%s-%d", process.worker.Name(), index)
+}
+
+// useInstance use the files of the instance index
+func (process *Process) useInstance(index int) {
+	process.pid.ServicesName, process.pid.index = process.instanceName(index), index
+}
+
+// joinInstance in the child, use the files of its instance
+func (process *Process) joinInstance() {
+	if index := process.Instance(); index >= 0 {
+		process.useInstance(index)
+	}
+}
+
+// instanceFilename the pid file of the instance index
+func (process *Process) instanceFilename(index int) string {
+	return filepath.Join(absolute(process.pid.SavePath), process.pid.expand(process.instanceName(index), strconv.Itoa(index)))
+}
+
+// instanceIndex the index of the instance of the pid file filename, false when it is not the pid file of an instance
+func (process *Process) instanceIndex(filename string) (int, bool) {
+	pattern := regexp.QuoteMeta(process.pid.pattern())
+	pattern = strings.Replace(pattern, "%s", regexp.QuoteMeta(process.worker.Name())+"-([0-9]+)", -1)
+	pattern = strings.Replace(pattern, "%d", "([0-9]+)", -1)
+	match := regexp.MustCompile("^" + pattern + "$").FindStringSubmatch(filepath.Base(filename))
+	if len(match) < 2 {
+		return 0, false
+	}
+	index, err := strconv.Atoi(match[1])
+	return index, err == nil && process.instanceFilename(index) == filepath.Join(absolute(process.pid.SavePath), filepath.Base(filename))
+}
+
+// instanceFiles the pid files of the running instances, in the order of their index
+func (process *Process) instanceFiles() []string {
+	files, _ := ioutil.ReadDir(absolute(process.pid.SavePath))
+	var indexes []int
+	for _, file := range files {
+		if index, ok := process.instanceIndex(file.Name()); ok {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Ints(indexes)
+	var filenames []string
+	for _, index := range indexes {
+		filenames = append(filenames, process.instanceFilename(index))
+	}
+	return filenames
+}
+
+// asInstances run fn for each instance of the pid files, with the files of the instance
+func (process *Process) asInstances(filenames []string, fn func() error) error {
+	name, index := process.pid.ServicesName, process.pid.index
+	defer func() { process.pid.ServicesName, process.pid.index = name, index }()
+	var failure error
+	for _, filename := range filenames {
+		if index, ok := process.instanceIndex(filename); ok {
+			process.useInstance(index)
+		} else {
+			// another instance of --all-instances, named after its pid file
+			base := filepath.Base(filename)
+			process.pid.ServicesName = strings.TrimSuffix(base, filepath.Ext(base))
+		}
+		if err := fn(); err != nil && failure == nil {
+			failure = err
+		}
+	}
+	return failure
+}
+
+// instanceCount how many instances the start command runs
+func (process *Process) instanceCount(cmd *cobra.Command) int {
+	if n, err := cmd.Flags().GetInt("instances"); err == nil && n > 0 {
+		return n
+	}
+	return process.instances
+}
+
+// launchInstances in the start command, spawn n children and wait for each to start
+func (process *Process) launchInstances(cmd *cobra.Command, n int) error {
+	var filenames []string
+	for index := 0; index < n; index++ {
+		filenames = append(filenames, process.instanceFilename(index))
+	}
+	defer os.Unsetenv(process.instanceEnv())
+	return process.asInstances(filenames, func() error {
+		_ = os.Setenv(process.instanceEnv(), strconv.Itoa(process.pid.index))
+		return launchChild(process, cmd, true)
+	})
+}
+
+// statusInstances the status command of the instances of the pid files, it exits with the code of the worst one
+func (process *Process) statusInstances(cmd *cobra.Command, filenames []string) error {
+	code := 0
+	_ = process.asInstances(filenames, func() error {
+		state, err := process.State()
+		result := stateResult(StatusCommand, state)
+		switch {
+		case err != nil:
+			report(cmd, result, "%s is not running\n", state.Name)
+		case !state.Running:
+			report(cmd, result, "%s is dead but its pid file %s exists (pid %d)\n", state.Name, state.PidFile, state.Pid)
+			code = ExitDead
+		default:
+			report(cmd, result, "%s", runningText(state))
+		}
+		return nil
+	})
+	if code != 0 {
+		return exitWith(code)
+	}
+	return nil
+}
+
+// restartInstances the restart command of the instances of the pid files, one after the other so that the others serve
+func (process *Process) restartInstances(cmd *cobra.Command, filenames []string, wait time.Duration) error {
+	return process.asInstances(filenames, func() error {
+		previous, err := process.pid.saved()
+		var pid int
+		if err == nil {
+			pid, err = process.pid.load(process.pid.SaveFilename())
+		}
+		if err == nil {
+			err = signalPid(pid, process.restartSignal)
+		}
+		if err == nil && wait >= 0 {
+			err = process.waitRestarted(previous, wait)
+		}
+		if err != nil {
+			return failed(process.result(RestartCommand, ""), err, 1)
+		}
+		report(cmd, process.result(RestartCommand, StateRestarted), "%s restarted\n", process.pid.ServicesName)
+		return nil
+	})
+}
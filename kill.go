@@ -0,0 +1,70 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// killWait how long kill waits for the killed process to be gone
+const killWait = 2 * time.Second
+
+func kill(worker *Process) *cobra.Command {
+	return &cobra.Command{
+		Use:   "kill",
+		Short: fmt.Sprintf("kill %s with SIGKILL, when a graceful stop hangs", worker.worker.Name()),
+		RunE: runE(func(cmd *cobra.Command, args []string) error {
+			if !worker.confirm(cmd, "kill") {
+				return exitWith(1)
+			}
+
+			filename := worker.pid.SaveFilename()
+			pid, err := worker.pid.load(filename)
+			switch {
+			case os.IsNotExist(err):
+				report(cmd, worker.result(KillCommand, StateNotRunning), "%s is not running\n", worker.worker.Name())
+				return nil
+			case errors.Is(err, syscall.ESRCH):
+				_ = worker.pid.forget(filename)
+				worker.removeArtifacts()
+				report(cmd, worker.result(KillCommand, StateNotRunning), "%s is not running: %v\n", worker.worker.Name(), err)
+				return nil
+			case err == nil:
+				err = worker.forceKill(pid, filename)
+			}
+			if err != nil {
+				return failed(worker.result(KillCommand, ""), err, 1)
+			}
+			result := worker.result(KillCommand, StateKilled)
+			result.Pid = pid
+			report(cmd, result, "%s (pid %d) killed\n", worker.worker.Name(), pid)
+			return nil
+		}),
+	}
+}
+
+// forceKill kill pid, which was read from the pid file filename, with SIGKILL together with the processes below it,
+// such as the worker of a supervisor, and remove the files it leaves behind
+func (process *Process) forceKill(pid int, filename string) error {
+	below := descendants(pid)
+	if err := signalPid(pid, os.Kill); err != nil {
+		return err
+	}
+	for _, child := range below {
+		if child, err := os.FindProcess(child); err == nil {
+			_ = child.Kill()
+		}
+	}
+	if process.killGroup {
+		_ = signalGroup(pid, syscall.SIGKILL)
+	}
+	waitExit(pid, killWait)
+	_ = process.pid.forget(filename)
+	_ = os.Remove(process.statusFilename())
+	process.removeArtifacts()
+	return nil
+}
@@ -0,0 +1,88 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// the Landlock system calls have the same numbers on every architecture
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockRulePathBeneath = 1
+
+	// access rights of the first Landlock ABI
+	landlockExecute   = 1 << 0
+	landlockWriteFile = 1 << 1
+	landlockReadFile  = 1 << 2
+	landlockReadDir   = 1 << 3
+	landlockAll       = 1<<13 - 1
+	landlockFile      = landlockExecute | landlockWriteFile | landlockReadFile
+
+	prSetNoNewPrivs = 38
+	oPath           = 0x200000
+)
+
+type landlockRulesetAttr struct {
+	handledAccessFS uint64
+}
+
+type landlockPathBeneathAttr struct {
+	allowedAccess uint64
+	parentFd      int32
+}
+
+// landlock enforce a ruleset allowing rules on the calling thread, and on the processes it starts
+func landlock(rules []LandlockRule) error {
+	attr := landlockRulesetAttr{handledAccessFS: landlockAll}
+	ruleset, _, errno := syscall.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return errno
+	}
+	defer syscall.Close(int(ruleset))
+
+	for _, rule := range rules {
+		if err := landlockAdd(int(ruleset), rule); err != nil {
+			return fmt.Errorf("%s: %v", rule.Path, err)
+		}
+	}
+
+	if _, _, errno = syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return errno
+	}
+	if _, _, errno = syscall.RawSyscall(sysLandlockRestrictSelf, ruleset, 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// landlockAdd allow rule in ruleset
+func landlockAdd(ruleset int, rule LandlockRule) error {
+	info, err := os.Stat(rule.Path)
+	if err != nil {
+		return err
+	}
+	fd, err := syscall.Open(rule.Path, oPath|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	access := uint64(landlockExecute | landlockReadFile | landlockReadDir)
+	if rule.Write {
+		access = landlockAll
+	}
+	if !info.IsDir() {
+		access &= landlockFile
+	}
+	beneath := landlockPathBeneathAttr{allowedAccess: access, parentFd: int32(fd)}
+	_, _, errno := syscall.Syscall6(sysLandlockAddRule, uintptr(ruleset), landlockRulePathBeneath, uintptr(unsafe.Pointer(&beneath)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package daemon
+
+// landlock Landlock only exists on Linux
+func landlock(rules []LandlockRule) error {
+	return ErrUnsupported
+}
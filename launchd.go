@@ -0,0 +1,124 @@
+package daemon
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// launchdDaemonPath directory of the generated launchd plists when installed by root, LaunchAgents of the user otherwise
+var launchdDaemonPath = "/Library/LaunchDaemons"
+
+// launchd runs the worker in the foreground, it is the supervisor. KeepAlive follows the restart policy:
+// "always" keeps it alive, "no" never restarts it, the others restart it when it did not exit successfully
+var launchdPlist = template.Must(template.New("launchd").Funcs(template.FuncMap{"xml": xmlEscape}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{xml .Name}}</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>{{xml .Executable}}</string>
+		{{- range .Command}}
+		<string>{{xml .}}</string>
+		{{- end}}
+		<string>--foreground</string>
+		{{- range .Arguments}}
+		<string>{{xml .}}</string>
+		{{- end}}
+	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	{{- if eq .Restart "always"}}
+	<true/>
+	{{- else if or (eq .Restart "no") (eq .Restart "")}}
+	<false/>
+	{{- else}}
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	{{- end}}
+</dict>
+</plist>
+`))
+
+// xmlEscape escape value for an XML text node
+func xmlEscape(value string) string {
+	var escaped strings.Builder
+	_ = xml.EscapeText(&escaped, []byte(value))
+	return escaped.String()
+}
+
+// launchd the integration of launchd plists, a daemon of the system domain when installed by root, an agent of the
+// user otherwise. `launchctl stop` sends SIGTERM, which stops the worker gracefully
+type launchd struct{}
+
+// domain the launchd domain of the services of this user
+func (launchd) domain() string {
+	if os.Geteuid() == 0 {
+		return "system"
+	}
+	return "gui/" + strconv.Itoa(os.Getuid())
+}
+
+func (launchd) filename(name string) string {
+	if os.Geteuid() == 0 {
+		return filepath.Join(launchdDaemonPath, name+".plist")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, "Library", "LaunchAgents", name+".plist")
+}
+
+func (launchd) render(svc *service) (string, error) {
+	var plist strings.Builder
+	err := launchdPlist.Execute(&plist, svc)
+	return plist.String(), err
+}
+
+// load launchd reads the plist when the service is bootstrapped
+func (launchd) load(svc *service) error {
+	return nil
+}
+
+func (system launchd) enable(name string, now bool) error {
+	if err := launchctl("enable", system.domain()+"/"+name); err != nil {
+		return err
+	}
+	if now {
+		return launchctl("bootstrap", system.domain(), system.filename(name))
+	}
+	return nil
+}
+
+func (system launchd) disable(name string, now bool) error {
+	if err := launchctl("disable", system.domain()+"/"+name); err != nil {
+		return err
+	}
+	if now {
+		return launchctl("bootout", system.domain()+"/"+name)
+	}
+	return nil
+}
+
+func (launchd) unload(name string) error {
+	return nil
+}
+
+func (system launchd) hint(name string) string {
+	return fmt.Sprintf("run `launchctl bootstrap %s %s` to start it", system.domain(), system.filename(name))
+}
+
+// launchctl run launchctl with the terminal attached
+func launchctl(args ...string) error {
+	cmd := exec.Command("launchctl", args...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	return cmd.Run()
+}
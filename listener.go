@@ -0,0 +1,118 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+)
+
+// ListenersEnv the environment variable that describes the listeners inherited by the child, the first one is fd 3
+const ListenersEnv = EnvName + "_LISTENERS"
+
+// listenerAddress what an inherited listener is listening on
+type listenerAddress struct {
+	Network string `json:"network"`
+	Address string `json:"address"`
+}
+
+// filer a listener whose file descriptor can be duplicated, such as *net.TCPListener and *net.UnixListener
+type filer interface {
+	File() (*os.File, error)
+}
+
+var listeners = struct {
+	sync.Mutex
+	inherited []listenerAddress // described by ListenersEnv, claimed entries are cleared
+	active    []listenerAddress
+	opened    []net.Listener
+}{}
+
+func init() {
+	if data := os.Getenv(ListenersEnv); data != "" {
+		_ = json.Unmarshal([]byte(data), &listeners.inherited)
+	}
+}
+
+// Listen like net.Listen, but the listener is inherited by the new child on restart,
+// so the new child accepts connections before the old one drains and none are refused in between
+func Listen(network, address string) (net.Listener, error) {
+	return listen(network, address, net.Listen)
+}
+
+// listen the listener inherited for address, or the one opened by open
+func listen(network, address string, open func(network, address string) (net.Listener, error)) (net.Listener, error) {
+	listeners.Lock()
+	defer listeners.Unlock()
+
+	wanted := listenerAddress{Network: network, Address: address}
+	var listener net.Listener
+	for i, inherited := range listeners.inherited {
+		if inherited != wanted {
+			continue
+		}
+		file := os.NewFile(uintptr(3+i), fmt.Sprintf("%s:%s", network, address))
+		inheritedListener, err := net.FileListener(file)
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("inherit listener %s %s: %v", network, address, err)
+		}
+		listeners.inherited[i] = listenerAddress{}
+		listener = inheritedListener
+		break
+	}
+
+	if listener == nil {
+		var err error
+		if listener, err = open(network, address); err != nil {
+			return nil, err
+		}
+	}
+	listeners.active = append(listeners.active, wanted)
+	listeners.opened = append(listeners.opened, listener)
+	return listener, nil
+}
+
+// listening whether listeners were opened by Listen, they are handed to the new child on restart
+func listening() bool {
+	listeners.Lock()
+	defer listeners.Unlock()
+	return len(listeners.opened) > 0
+}
+
+// inheritable duplicate the file descriptors of the listeners opened by Listen, and describe them for the new child
+func inheritable() ([]*os.File, string, error) {
+	listeners.Lock()
+	defer listeners.Unlock()
+	if len(listeners.opened) == 0 {
+		return nil, "", nil
+	}
+
+	var files []*os.File
+	for i, listener := range listeners.opened {
+		listenerFiler, ok := listener.(filer)
+		if !ok {
+			return nil, "", fmt.Errorf("listener %s %s cannot be inherited", listeners.active[i].Network, listeners.active[i].Address)
+		}
+		file, err := listenerFiler.File()
+		if err != nil {
+			return nil, "", err
+		}
+		files = append(files, file)
+	}
+	data, err := json.Marshal(listeners.active)
+	return files, fmt.Sprintf("%s=%s", ListenersEnv, data), err
+}
+
+// inherited the listener files inherited from the previous child, to pass them on unchanged
+func inherited() []*os.File {
+	listeners.Lock()
+	defer listeners.Unlock()
+
+	var files []*os.File
+	for i, inherited := range listeners.inherited {
+		files = append(files, os.NewFile(uintptr(3+i), fmt.Sprintf("%s:%s", inherited.Network, inherited.Address)))
+	}
+	return files
+}
@@ -0,0 +1,80 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ResourceLock an exclusive lock on a resource shared by the workers of the same binary, such as a data directory or a device.
+// it is a file lock, so the kernel releases it when the holder crashes
+type ResourceLock struct {
+	Resource string
+	file     *os.File
+	process  *Process
+}
+
+// lockDir the directory of the lock files, shared by every worker of the binary
+func lockDir() string {
+	return filepath.Join(os.TempDir(), Name()+".locks")
+}
+
+// Lock acquire the exclusive lock of resource, waiting up to timeout for the current holder (zero tries once).
+// resource names a file in the lock directory, so it can't contain a path separator. locks are released
+// automatically when the process stops
+func (process *Process) Lock(resource string, timeout time.Duration) (*ResourceLock, error) {
+	if resource == "" || resource == "." || resource == ".." || strings.ContainsAny(resource, `/\`) {
+		return nil, fmt.Errorf("lock %q: invalid resource name", resource)
+	}
+	if err := os.MkdirAll(lockDir(), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(lockDir(), resource+".lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		if err = lock(file); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			_ = file.Close()
+			return nil, fmt.Errorf("lock %s: held by another worker: %v", resource, err)
+		}
+		time.Sleep(waitInterval)
+	}
+
+	resourceLock := &ResourceLock{Resource: resource, file: file, process: process}
+	process.locksMutex.Lock()
+	process.locks = append(process.locks, resourceLock)
+	process.locksMutex.Unlock()
+	return resourceLock, nil
+}
+
+// Unlock release the lock
+func (resourceLock *ResourceLock) Unlock() error {
+	process := resourceLock.process
+	process.locksMutex.Lock()
+	for i, held := range process.locks {
+		if held == resourceLock {
+			process.locks = append(process.locks[:i], process.locks[i+1:]...)
+			break
+		}
+	}
+	process.locksMutex.Unlock()
+	return resourceLock.file.Close()
+}
+
+// unlockAll release every lock acquired through Lock
+func (process *Process) unlockAll() {
+	process.locksMutex.Lock()
+	defer process.locksMutex.Unlock()
+	for _, resourceLock := range process.locks {
+		_ = resourceLock.file.Close()
+	}
+	process.locks = nil
+}
@@ -0,0 +1,101 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logger receives the lifecycle events of the daemon (started, pid saved, signal received, restart triggered...)
+// and its internal errors. args are alternating keys and values, a *slog.Logger satisfies it
+type Logger interface {
+	Info(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+var (
+	globalLogger      Logger
+	globalLoggerMutex sync.RWMutex
+)
+
+// SetLogger set the logger of every Process that has none of its own
+func SetLogger(logger Logger) {
+	globalLoggerMutex.Lock()
+	globalLogger = logger
+	globalLoggerMutex.Unlock()
+}
+
+// SetLogger set the logger of the process, instead of the global one
+func (process *Process) SetLogger(logger Logger) *Process {
+	return process.configure("SetLogger", func() {
+		process.log = logger
+	})
+}
+
+// logger the logger of the process, the global one or lines written to the output of the worker
+func (process *Process) logger() Logger {
+	if process.log != nil {
+		return process.log
+	}
+	globalLoggerMutex.RLock()
+	defer globalLoggerMutex.RUnlock()
+	if globalLogger != nil {
+		return globalLogger
+	}
+	return &process.defaultLog
+}
+
+// info log a lifecycle event of the worker
+func (process *Process) info(msg string, args ...interface{}) {
+	process.logger().Info(msg, append([]interface{}{"worker", process.worker.Name()}, args...)...)
+}
+
+// error log an error of the worker
+func (process *Process) error(msg string, args ...interface{}) {
+	process.logger().Error(msg, append([]interface{}{"worker", process.worker.Name()}, args...)...)
+}
+
+// writerLogger the default logger, writes one key=value line per record
+type writerLogger struct {
+	mutex  sync.Mutex
+	output func() io.Writer
+}
+
+// Info write an INFO record
+func (logger *writerLogger) Info(msg string, args ...interface{}) {
+	logger.write("INFO", msg, args)
+}
+
+// Error write an ERROR record
+func (logger *writerLogger) Error(msg string, args ...interface{}) {
+	logger.write("ERROR", msg, args)
+}
+
+// write format the record and write it in one call
+func (logger *writerLogger) write(level, msg string, args []interface{}) {
+	var line strings.Builder
+	fmt.Fprintf(&line, "time=%s level=%s msg=%s", time.Now().Format(time.RFC3339), level, quote(msg))
+	for i := 0; i < len(args); i += 2 {
+		if i+1 < len(args) {
+			fmt.Fprintf(&line, " %v=%s", args[i], quote(fmt.Sprint(args[i+1])))
+		} else {
+			fmt.Fprintf(&line, " !BADKEY=%s", quote(fmt.Sprint(args[i])))
+		}
+	}
+	line.WriteByte('\n')
+
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	_, _ = io.WriteString(logger.output(), line.String())
+}
+
+// quote quote value when it would be ambiguous in a key=value line
+func quote(value string) string {
+	if value == "" || strings.ContainsAny(value, " \"=\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
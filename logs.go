@@ -0,0 +1,174 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// logsBlock how much of a log file is read at a time looking for the last lines
+	logsBlock = 64 * 1024
+	// logsInterval how often followed log files are checked for new lines
+	logsInterval = 250 * time.Millisecond
+)
+
+// namer an output that is a file, such as *os.File and *RotatingLog
+type namer interface {
+	Name() string
+}
+
+// logFiles the files the standard output and error of the worker are written to, once each.
+// the files of SetLogFiles win over the outputs of the pipeline, as in the child
+func (process *Process) logFiles() []string {
+	var filenames []string
+	seen := make(map[string]bool)
+	for i, output := range []io.Writer{process.outputs[0], process.outputs[1]} {
+		if output == nil && process.pipeline[i+1] != nil {
+			output = process.pipeline[i+1]
+		}
+		name := process.logPaths[i]
+		if file, ok := output.(namer); ok && name == "" && output != os.Stdout && output != os.Stderr {
+			name = file.Name()
+		}
+		if name == "" {
+			continue
+		}
+		filename, err := filepath.Abs(name)
+		if err != nil || seen[filename] {
+			continue
+		}
+		seen[filename] = true
+		filenames = append(filenames, filename)
+	}
+	return filenames
+}
+
+// lastLines the last n lines of the file
+func lastLines(file *os.File, n int) ([]byte, error) {
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	var tail []byte
+	offset := size
+	for offset > 0 && bytes.Count(tail, []byte("\n")) <= n {
+		block := int64(logsBlock)
+		if offset < block {
+			block = offset
+		}
+		offset -= block
+		chunk := make([]byte, block)
+		if _, err = file.ReadAt(chunk, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		tail = append(chunk, tail...)
+	}
+
+	// keep the last n lines, the last one may not be terminated yet
+	lines := bytes.SplitAfter(tail, []byte("\n"))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	_, err = file.Seek(size, io.SeekStart)
+	return bytes.Join(lines, nil), err
+}
+
+// followed a log file being followed
+type followed struct {
+	filename string
+	file     *os.File
+	offset   int64  // how much of the file was read
+	pending  []byte // the incomplete last line
+}
+
+// read print the lines added to the file, it is opened again when it was rotated or truncated
+func (followed *followed) read(out io.Writer) {
+	if info, err := os.Stat(followed.filename); err == nil {
+		current, err := followed.file.Stat()
+		if err != nil || !os.SameFile(info, current) || info.Size() < followed.offset {
+			if file, err := os.Open(followed.filename); err == nil {
+				_ = followed.file.Close()
+				followed.file, followed.offset, followed.pending = file, 0, nil
+			}
+		}
+	}
+	data, _ := ioutil.ReadAll(followed.file)
+	followed.offset += int64(len(data))
+	followed.pending = append(followed.pending, data...)
+	// an incomplete line is printed once it is complete
+	if end := bytes.LastIndexByte(followed.pending, '\n'); end >= 0 {
+		_, _ = out.Write(followed.pending[:end+1])
+		followed.pending = append([]byte(nil), followed.pending[end+1:]...)
+	}
+}
+
+// tailLogs print the last lines of the files, then the lines written to them while follow
+func tailLogs(out io.Writer, filenames []string, lines int, follow bool) error {
+	var files []*followed
+	defer func() {
+		for _, file := range files {
+			_ = file.file.Close()
+		}
+	}()
+	for i, filename := range filenames {
+		file, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		tail, err := lastLines(file, lines)
+		if err != nil {
+			_ = file.Close()
+			return err
+		}
+		if len(filenames) > 1 {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprintf(out, "==> %s <==\n", filename)
+		}
+		_, _ = out.Write(tail)
+		offset, _ := file.Seek(0, io.SeekCurrent)
+		files = append(files, &followed{filename: filename, file: file, offset: offset})
+	}
+
+	for follow {
+		time.Sleep(logsInterval)
+		for _, file := range files {
+			file.read(out)
+		}
+	}
+	return nil
+}
+
+func logs(worker *Process) *cobra.Command {
+	logs := &cobra.Command{
+		Use:   "logs",
+		Short: fmt.Sprintf("print the output of %s", worker.worker.Name()),
+		Long:  "print the last lines of the files the standard output and error of the worker are written to, see SetPipeline and SetOutput",
+		RunE: runE(func(cmd *cobra.Command, args []string) error {
+			filenames := worker.logFiles()
+			if len(filenames) == 0 {
+				return failed(worker.result(LogsCommand, ""), fmt.Errorf("the output of %s is not written to a file", worker.worker.Name()), 1)
+			}
+			lines, _ := cmd.Flags().GetInt("lines")
+			follow, _ := cmd.Flags().GetBool("follow")
+			if err := tailLogs(os.Stdout, filenames, lines, follow); err != nil {
+				return failed(worker.result(LogsCommand, ""), err, 1)
+			}
+			return nil
+		}),
+	}
+	logs.Flags().IntP("lines", "n", 10, "number of lines to print")
+	logs.Flags().BoolP("follow", "f", false, "print the lines written afterwards until interrupted")
+	return logs
+}
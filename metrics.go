@@ -0,0 +1,138 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics a snapshot of the daemon internals, to feed an existing metrics registry
+type Metrics struct {
+	Worker     string
+	Pid        int
+	Started    time.Time
+	Uptime     time.Duration
+	Restarts   int       // restarts by the restart command, health checks and the supervisor since the first start
+	Ready      bool      // the worker reported it is ready
+	Healthy    bool      // the last health check passed, always true without HealthChecker
+	LastSignal string    // name of the last signal handled, empty if none
+	SignaledAt time.Time // when LastSignal was handled
+}
+
+// signalHistory the last signals handled by the dispatcher
+type signalHistory struct {
+	sync.Mutex
+	records []SignalRecord
+}
+
+// restartsEnv name of the environment variable that counts the restarts for the new child
+func (process *Process) restartsEnv() string {
+	return process.daemonTag + "_RESTARTS"
+}
+
+// restarts how often the worker was restarted before this process
+func (process *Process) restarts() int {
+	restarts, _ := strconv.Atoi(os.Getenv(process.restartsEnv()))
+	return restarts
+}
+
+// signaled record the signal handled by the dispatcher
+func (process *Process) signaled(sig os.Signal) {
+	process.history.Lock()
+	if len(process.history.records) == signalHistorySize {
+		process.history.records = process.history.records[1:]
+	}
+	process.history.records = append(process.history.records, SignalRecord{Signal: sig.String(), At: time.Now()})
+	process.history.Unlock()
+}
+
+// Metrics the current metrics of the running worker, meaningful in the child
+func (process *Process) Metrics() Metrics {
+	var last SignalRecord
+	process.history.Lock()
+	if len(process.history.records) > 0 {
+		last = process.history.records[len(process.history.records)-1]
+	}
+	process.history.Unlock()
+	return Metrics{
+		Worker:     process.worker.Name(),
+		Pid:        os.Getpid(),
+		Started:    process.started,
+		Uptime:     time.Since(process.started),
+		Restarts:   process.restarts(),
+		Ready:      atomic.LoadInt32(&process.isReady) == 1,
+		Healthy:    atomic.LoadInt32(&process.unhealthy) == 0,
+		LastSignal: last.Signal,
+		SignaledAt: last.At,
+	}
+}
+
+// MetricsHandler serve the metrics in the Prometheus text format, to be mounted on an existing HTTP server
+func (process *Process) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		process.Metrics().write(writer)
+	})
+}
+
+// EnableMetrics serve the metrics on address, such as ":9100", from the child at /metrics
+func (process *Process) EnableMetrics(address string) *Process {
+	return process.configure("EnableMetrics", func() {
+		process.metricsAddress = address
+	})
+}
+
+// serveMetrics in the child, serve the metrics if enabled. the listener is handed to the new child on restart
+func (process *Process) serveMetrics() error {
+	if process.metricsAddress == "" {
+		return nil
+	}
+	listener, err := Listen("tcp", process.metricsAddress)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", process.MetricsHandler())
+	go func() {
+		_ = http.Serve(listener, mux)
+	}()
+	return nil
+}
+
+// write the metrics in the Prometheus text format
+func (metrics Metrics) write(writer io.Writer) {
+	labels := fmt.Sprintf(`worker="%s"`, escapeLabel(metrics.Worker))
+	gauge := func(name, help string, value float64, extra string) {
+		fmt.Fprintf(writer, "# HELP %s %s\n# TYPE %s gauge\n%s{%s%s} %s\n", name, help, name, name, labels, extra,
+			strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	boolean := func(value bool) float64 {
+		if value {
+			return 1
+		}
+		return 0
+	}
+
+	gauge("daemon_pid", "Process id of the worker.", float64(metrics.Pid), "")
+	gauge("daemon_start_time_seconds", "Start time of the worker since unix epoch in seconds.", float64(metrics.Started.UnixNano())/1e9, "")
+	gauge("daemon_uptime_seconds", "Seconds since the worker started.", metrics.Uptime.Seconds(), "")
+	fmt.Fprintf(writer, "# HELP daemon_restarts_total Restarts of the worker.\n# TYPE daemon_restarts_total counter\ndaemon_restarts_total{%s} %d\n",
+		labels, metrics.Restarts)
+	gauge("daemon_ready", "Whether the worker reported it is ready.", boolean(metrics.Ready), "")
+	gauge("daemon_healthy", "Whether the last health check of the worker passed.", boolean(metrics.Healthy), "")
+	if metrics.LastSignal != "" {
+		gauge("daemon_last_signal_timestamp_seconds", "When the last signal was handled, since unix epoch in seconds.",
+			float64(metrics.SignaledAt.UnixNano())/1e9, fmt.Sprintf(`,signal="%s"`, escapeLabel(metrics.LastSignal)))
+	}
+}
+
+// escapeLabel escape a Prometheus label value
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
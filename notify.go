@@ -0,0 +1,88 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// NotifySocketEnv the environment variable systemd sets to the socket of sd_notify with Type=notify
+const NotifySocketEnv = "NOTIFY_SOCKET"
+
+// ReadyNotifier If the worker implements this interface, it decides when it is ready instead of Start being called:
+// SetReady receives a function to call once it serves, it tells systemd READY=1 and starts the watchdog pings
+type ReadyNotifier interface {
+	SetReady(ready func())
+}
+
+// sdNotify send state to the service manager, nothing without NOTIFY_SOCKET
+func sdNotify(state string) error {
+	socket := os.Getenv(NotifySocketEnv)
+	if socket == "" {
+		return nil
+	}
+	// abstract socket
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// notify send state to the service manager, a failure is only logged
+func (process *Process) notify(state string) {
+	if err := sdNotify(state); err != nil {
+		process.error("notify service manager failed", "state", state, "err", err)
+	}
+}
+
+// ready report that the worker is ready, once, and start watching it
+func (process *Process) ready() bool {
+	if process.readyDropped {
+		return false
+	}
+	process.readyOnce.Do(func() {
+		atomic.StoreInt32(&process.isReady, 1)
+		process.info("ready")
+		process.writeStatus()
+		process.notify(fmt.Sprintf("READY=1\nMAINPID=%d", os.Getpid()))
+		process.watchdog()
+		go process.probeHealth()
+		go process.watchFiles()
+	})
+	return true
+}
+
+// watchdogInterval half the watchdog timeout systemd expects pings within, zero when the watchdog is off
+// or belongs to another process
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// watchdog ping the systemd watchdog until the process exits
+func (process *Process) watchdog() {
+	interval := watchdogInterval()
+	if interval == 0 {
+		return
+	}
+	go func() {
+		for range time.Tick(interval) {
+			process.notify("WATCHDOG=1")
+		}
+	}()
+}
@@ -0,0 +1,56 @@
+package daemon
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// ExitCoder If the worker implements this interface, ExitCode is the exit code of the child once Start returned
+// in the foreground or with SetOneShot, 0 otherwise
+type ExitCoder interface {
+	ExitCode() int
+}
+
+// ExitError returned by Run in the start command when the job of SetOneShot completed with a code other than 0
+type ExitError struct {
+	Name string
+	Code int
+}
+
+func (err *ExitError) Error() string {
+	return fmt.Sprintf("%s exited with code %d", err.Name, err.Code)
+}
+
+// SetOneShot run the worker to completion: once Start returns the child removes the pid file and exits with the code of ExitCoder.
+// the start command blocks until then and exits with the same code, --wait bounds how long it waits, negative returns immediately
+func (process *Process) SetOneShot(oneShot bool) *Process {
+	return process.configure("SetOneShot", func() {
+		process.oneShot = oneShot
+	})
+}
+
+// exitCode the code the child exits with once the worker returned
+func (process *Process) exitCode() int {
+	if worker, ok := process.impl.(ExitCoder); ok {
+		return worker.ExitCode()
+	}
+	return 0
+}
+
+// completes whether the child exits when Start returns, unless it was stopped or restarted meanwhile
+func (process *Process) completes(generation int32) bool {
+	return (process.foreground || process.oneShot || process.returned()) && atomic.LoadInt32(&process.terminating) == 0 &&
+		atomic.LoadInt32(&process.generation) == generation
+}
+
+// reportExit in the child of a one-shot worker, tell the start command the exit code
+func (process *Process) reportExit(code int) {
+	process.startupMutex.Lock()
+	defer process.startupMutex.Unlock()
+	if process.startup == nil {
+		return
+	}
+	_, _ = fmt.Fprintf(process.startup, "exit %d\n", code)
+	_ = process.startup.Close()
+	process.startup = nil
+}
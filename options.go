@@ -0,0 +1,189 @@
+package daemon
+
+import (
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// StartCommand name of the generated start command
+	StartCommand = "start"
+	// StopCommand name of the generated stop command
+	StopCommand = "stop"
+	// RestartCommand name of the generated restart command
+	RestartCommand = "restart"
+	// UpgradeCommand name of the generated command that replaces the running worker with the binary on disk
+	UpgradeCommand = "upgrade"
+	// SelfUpdateCommand name of the generated command that downloads a new binary, see Process.EnableSelfUpdate
+	SelfUpdateCommand = "self-update"
+	// ReloadCommand name of the generated reload command, only generated for a Reloader
+	ReloadCommand = "reload"
+	// KillCommand name of the generated command that kills the worker with SIGKILL
+	KillCommand = "kill"
+	// StatusCommand name of the generated status command
+	StatusCommand = "status"
+	// EnableCommand name of the generated command that registers boot-time start
+	EnableCommand = "enable"
+	// DisableCommand name of the generated command that deregisters boot-time start
+	DisableCommand = "disable"
+	// InstallCommand name of the generated command that writes a systemd unit
+	InstallCommand = "install"
+	// UninstallCommand name of the generated command that removes the systemd unit
+	UninstallCommand = "uninstall"
+	// ControlCommand name of the generated command that sends a request to the control socket, see Process.EnableControl
+	ControlCommand = "control"
+	// LogsCommand name of the generated command that prints the output files of the worker
+	LogsCommand = "logs"
+	// EnqueueCommand name of the generated command that adds a job to the queue, see Process.EnableQueue
+	EnqueueCommand = "enqueue"
+)
+
+// verbs the lifecycle verbs in the order they are added to the command tree
+var verbs = []string{StartCommand, StopCommand, KillCommand, RestartCommand, UpgradeCommand, SelfUpdateCommand, ReloadCommand, StatusCommand, EnableCommand, DisableCommand, InstallCommand, UninstallCommand, LogsCommand, ControlCommand, EnqueueCommand}
+
+// CommandOption customize the lifecycle commands generated for a worker, the map key is the verb (StartCommand, StopCommand...)
+type CommandOption func(commands map[string]*cobra.Command)
+
+// RenameCommand use name instead of the default verb, such as RenameCommand(StopCommand, "shutdown")
+func RenameCommand(verb, name string) CommandOption {
+	return func(commands map[string]*cobra.Command) {
+		if cmd, ok := commands[verb]; ok {
+			cmd.Use = name
+		}
+	}
+}
+
+// AliasCommand add aliases to the verb, such as AliasCommand(RestartCommand, "reload")
+func AliasCommand(verb string, aliases ...string) CommandOption {
+	return func(commands map[string]*cobra.Command) {
+		if cmd, ok := commands[verb]; ok {
+			cmd.Aliases = append(cmd.Aliases, aliases...)
+		}
+	}
+}
+
+// WithoutCommand do not generate the verb, for operations that must only be done through another system
+func WithoutCommand(verb string) CommandOption {
+	return func(commands map[string]*cobra.Command) {
+		delete(commands, verb)
+	}
+}
+
+// WithoutStartCommand do not generate the start command
+func WithoutStartCommand() CommandOption {
+	return WithoutCommand(StartCommand)
+}
+
+// WithoutStopCommand do not generate the stop command
+func WithoutStopCommand() CommandOption {
+	return WithoutCommand(StopCommand)
+}
+
+// WithoutRestartCommand do not generate the restart command, such as restart only via orchestrator
+func WithoutRestartCommand() CommandOption {
+	return WithoutCommand(RestartCommand)
+}
+
+// HideCommand keep the verb usable but hide it from help output
+func HideCommand(verb string) CommandOption {
+	return func(commands map[string]*cobra.Command) {
+		if cmd, ok := commands[verb]; ok {
+			cmd.Hidden = true
+		}
+	}
+}
+
+// HideStart hide the start command from help output
+func HideStart() CommandOption {
+	return HideCommand(StartCommand)
+}
+
+// HideStop hide the stop command from help output
+func HideStop() CommandOption {
+	return HideCommand(StopCommand)
+}
+
+// HideRestart hide the restart command from help output
+func HideRestart() CommandOption {
+	return HideCommand(RestartCommand)
+}
+
+// CustomizeCommand hand the generated cobra command of verb to fn, for anything the other options do not cover
+func CustomizeCommand(verb string, fn func(cmd *cobra.Command)) CommandOption {
+	return func(commands map[string]*cobra.Command) {
+		if cmd, ok := commands[verb]; ok {
+			fn(cmd)
+		}
+	}
+}
+
+// Describe replace the auto-generated descriptions of verb, empty values keep the current text
+func Describe(verb, short, long, example string) CommandOption {
+	return CustomizeCommand(verb, func(cmd *cobra.Command) {
+		if short != "" {
+			cmd.Short = short
+		}
+		if long != "" {
+			cmd.Long = long
+		}
+		if example != "" {
+			cmd.Example = example
+		}
+	})
+}
+
+// PersistentPreRun run fn before every generated command of the worker, a returned error aborts the command.
+// the PersistentPreRunE or PersistentPreRun already set on the command, by an earlier option for instance, runs first
+func PersistentPreRun(fn func(cmd *cobra.Command, args []string) error) CommandOption {
+	return func(commands map[string]*cobra.Command) {
+		for _, cmd := range commands {
+			previousE, previous := cmd.PersistentPreRunE, cmd.PersistentPreRun
+			cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+				switch {
+				case previousE != nil:
+					if err := previousE(cmd, args); err != nil {
+						return err
+					}
+				case previous != nil:
+					previous(cmd, args)
+				}
+				return fn(cmd, args)
+			}
+		}
+	}
+}
+
+// ProcessOption configure a Process in NewProcess, before Run, instead of setting it once it may be running
+type ProcessOption func(process *Process)
+
+// WithPipeline see SetPipeline
+func WithPipeline(pipes ...*os.File) ProcessOption {
+	return func(process *Process) { process.SetPipeline(pipes...) }
+}
+
+// WithStopTimeout see SetStopTimeout
+func WithStopTimeout(timeout time.Duration) ProcessOption {
+	return func(process *Process) { process.SetStopTimeout(timeout) }
+}
+
+// WithDaemonTag see SetDaemonTag
+func WithDaemonTag(name string) ProcessOption {
+	return func(process *Process) { process.SetDaemonTag(name) }
+}
+
+// WithPidDirMode mode of the pid directory when it has to be created, DefaultPidDirMode by default
+func WithPidDirMode(mode os.FileMode) ProcessOption {
+	return func(process *Process) { process.pid.DirMode = mode }
+}
+
+// WithPidFilename see Pid.SetFilename
+func WithPidFilename(pattern string) ProcessOption {
+	return func(process *Process) { process.pid.SetFilename(pattern) }
+}
+
+// WithRuntimeDir keep the pid file in the runtime directory, see Pid.UseRuntimeDir
+func WithRuntimeDir() ProcessOption {
+	return func(process *Process) { process.pid.UseRuntimeDir() }
+}
@@ -0,0 +1,117 @@
+package daemon
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// reopener an output that can reopen its file, such as *RotatingLog
+type reopener interface {
+	Reopen() error
+}
+
+// SetOutput send the standard output and error of the worker to writers instead of files, such as a *RotatingLog.
+// it applies in the child, nil keeps the pipeline. outputs that can be reopened are reopened on SIGHUP, so logrotate works too
+func (process *Process) SetOutput(stdout, stderr io.Writer) *Process {
+	return process.configure("SetOutput", func() {
+		process.outputs = [2]io.Writer{stdout, stderr}
+	})
+}
+
+// SetLogFiles write the standard output and error of the worker to the files at stdoutPath and stderrPath, which can be the same.
+// they are opened in append mode when the child is started, created with their directories, and reopened on SIGHUP for logrotate.
+// an empty path keeps the pipeline, SetPipeline and SetOutput remain for other targets
+func (process *Process) SetLogFiles(stdoutPath, stderrPath string) *Process {
+	return process.configure("SetLogFiles", func() {
+		process.logPaths = [2]string{stdoutPath, stderrPath}
+	})
+}
+
+// openLogFiles open the files of SetLogFiles, nil for an empty path. a path given twice is opened once
+func (process *Process) openLogFiles() ([2]*os.File, error) {
+	var files [2]*os.File
+	for i, path := range process.logPaths {
+		switch {
+		case path == "":
+		case i == 1 && path == process.logPaths[0]:
+			files[1] = files[0]
+		default:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return files, err
+			}
+			file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				return files, err
+			}
+			files[i] = file
+		}
+	}
+	return files, nil
+}
+
+// redirectLogFiles in the child, point the standard output and error at newly opened files of SetLogFiles,
+// so that everything written to them, panics included, goes to the current files
+func (process *Process) redirectLogFiles() error {
+	files, err := process.openLogFiles()
+	if err != nil {
+		return err
+	}
+	for i, file := range files {
+		if file == nil {
+			continue
+		}
+		if err = dup2(int(file.Fd()), i+1); err != nil {
+			return err
+		}
+	}
+	for i, file := range files {
+		if file != nil && (i == 0 || file != files[0]) {
+			_ = file.Close()
+		}
+	}
+	return nil
+}
+
+// reopenOutputs reopen every output that supports it
+func (process *Process) reopenOutputs() {
+	if process.logPaths != [2]string{} {
+		if err := process.redirectLogFiles(); err != nil {
+			process.error("reopen log files failed", "err", err)
+		}
+	}
+	for _, output := range process.outputs {
+		if output, ok := output.(reopener); ok {
+			if err := output.Reopen(); err != nil {
+				process.error("reopen output failed", "err", err)
+			}
+		}
+	}
+}
+
+// stdout where the process writes its own messages
+func (process *Process) stdout() io.Writer {
+	if process.outputs[0] != nil {
+		return process.outputs[0]
+	}
+	return process.pipeline[1]
+}
+
+// redirectOutput in the child, let os.Stdout and os.Stderr feed the outputs of SetOutput
+func (process *Process) redirectOutput() error {
+	for i, target := range []**os.File{&os.Stdout, &os.Stderr} {
+		output := process.outputs[i]
+		if output == nil {
+			continue
+		}
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		go func() {
+			_, _ = io.Copy(output, reader)
+		}()
+		*target = writer
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package daemon
+
+import "net"
+
+// peerCredentials whether peerUID works on this platform
+const peerCredentials = false
+
+// peerUID the peer credentials are only read on Linux
+func peerUID(conn *net.UnixConn) (int, error) {
+	return -1, ErrUnsupported
+}
@@ -1,39 +1,216 @@
 package daemon
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Pid The process id information and process pid file descriptors that are mainly recorded here
 type Pid struct {
-	ServicesName string   // service name, not process name
-	SavePath     string   // pid save path
-	Pid          int      // pid num
-	File         *os.File // file
+	ServicesName string      // service name, not process name
+	SavePath     string      // pid save path
+	Pid          int         // pid num
+	File         *os.File    // file
+	DirMode      os.FileMode // mode of the pid directory when it has to be created, DefaultPidDirMode if zero
+	Store        StateStore  // records the pid instead of the pid file when set, see SetStateStore
+	Filename     string      // pattern of the name of the pid file, DefaultPidFilename if empty, see SetFilename
+
+	dir   *os.File // the pid directory, kept open when its path is no longer reachable
+	index int      // the index of the instance, see SetInstances
+}
+
+// DefaultPidFilename the name of the pid file, <name>.pid
+const DefaultPidFilename = "%s.pid"
+
+// DefaultPidDirMode mode of a missing pid directory
+const DefaultPidDirMode os.FileMode = 0755
+
+// UseRuntimeDir save the pid file in the runtime directory of the service: /run/<name> (/var/run/<name> outside Linux)
+// for root, $XDG_RUNTIME_DIR/<name> for other users, the temporary directory without XDG_RUNTIME_DIR.
+// the directory is created when the pid file is saved
+func (pid *Pid) UseRuntimeDir() *Pid {
+	var dir string
+	switch {
+	case runtime.GOOS == "windows":
+		dir = os.TempDir()
+	case os.Geteuid() == 0 && runtime.GOOS == "linux":
+		dir = "/run"
+	case os.Geteuid() == 0:
+		dir = "/var/run"
+	case os.Getenv("XDG_RUNTIME_DIR") != "":
+		dir = os.Getenv("XDG_RUNTIME_DIR")
+	default:
+		dir = os.TempDir()
+	}
+	pid.SavePath = filepath.Join(dir, pid.ServicesName)
+	return pid
+}
+
+// SetFilename name the pid file after pattern instead of DefaultPidFilename, such as "%s.lock" or "myapp-%d.pid":
+// %s is replaced by the service name, <name>-<index> for an instance of SetInstances, %d by the index of the instance,
+// 0 for a worker run alone, which the commands then treat like instance 0
+func (pid *Pid) SetFilename(pattern string) *Pid {
+	pid.Filename = pattern
+	return pid
+}
+
+// pattern the pattern of the name of the pid file
+func (pid Pid) pattern() string {
+	if pid.Filename == "" {
+		return DefaultPidFilename
+	}
+	return pid.Filename
+}
+
+// expand the pattern of the name of the pid file with name for %s and index for %d
+func (pid Pid) expand(name, index string) string {
+	return strings.Replace(strings.Replace(pid.pattern(), "%s", name, -1), "%d", index, -1)
+}
+
+// SetSavePath save the pid file in path, resolved against the working directory now rather than on every use
+func (pid *Pid) SetSavePath(path string) *Pid {
+	pid.SavePath = absolute(path)
+	return pid
 }
 
 // SaveFilename Get the path where the pid is saved
 func (pid Pid) SaveFilename() string {
+	dir := pid.SavePath
+	if !filepath.IsAbs(dir) {
+		// set directly to a relative path instead of with SetSavePath
+		dir = absolute(dir)
+	}
+	return filepath.Join(dir, pid.expand(pid.ServicesName, strconv.Itoa(pid.index)))
+}
+
+// Instances the pid files of every instance of the service, <pid-dir>/<name>-*.pid with DefaultPidFilename
+func (pid Pid) Instances() ([]string, error) {
 	path, err := filepath.Abs(pid.SavePath)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	return filepath.Glob(filepath.Join(path, pid.expand(pid.ServicesName+"-*", "*")))
+}
+
+// Read read the pid recorded in the pid file, or in the Store
+func (pid Pid) Read() (int, error) {
+	record, err := pid.record(pid.SaveFilename())
+	return record.Pid, err
+}
+
+// readPid read the pid of the pid file filename
+func readPid(filename string) (int, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// load the pid recorded in the pid file filename or for it in the Store, wrapping syscall.ESRCH when it is not
+// a process of this binary
+func (pid Pid) load(filename string) (int, error) {
+	record, err := pid.record(filename)
+	var invalid *strconv.NumError
+	if errors.As(err, &invalid) {
+		return 0, fmt.Errorf("invalid pid file %s: %v: %w", filename, err, ErrStalePid)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return record.Pid, pid.verify(record.Pid)
+}
+
+// verify fail with ErrStalePid unless the process number runs this binary, or the executable the status file recorded
+// for it, such as the previous release of a deployment: the pid was reused by another program, which must not be signaled
+func (pid Pid) verify(number int) error {
+	if sameBinary(number) {
+		return nil
+	}
+	path, err := processExecutable(number)
+	if err != nil {
+		return fmt.Errorf("process %d is not %s, the pid was reused: %w", number, executable(), ErrStalePid)
+	}
+	path = strings.TrimSuffix(path, " (deleted)")
+	if status := readStatusFile(pid.statusFilename()); status != nil && status.Pid == number && status.Executable == path {
+		return nil
+	}
+	return fmt.Errorf("process %d runs %s, not %s, the pid was reused: %w", number, path, executable(), ErrStalePid)
+}
+
+// statusFilename path of the status file, next to the pid file
+func (pid Pid) statusFilename() string {
+	return filepath.Join(filepath.Dir(pid.SaveFilename()), pid.ServicesName+".status")
+}
+
+// signal send sig to the process recorded in the pid file filename, returns its pid
+func (pid Pid) signal(filename string, sig os.Signal) (int, error) {
+	number, err := pid.load(filename)
+	if err != nil {
+		return number, err
 	}
+	return number, signalPid(number, sig)
+}
 
-	return fmt.Sprintf("%s/%s.pid", path, pid.ServicesName)
+// saved when the pid was saved, a restarted worker saves it again
+func (pid Pid) saved() (time.Time, error) {
+	record, err := pid.record(pid.SaveFilename())
+	return record.Saved, err
 }
 
-// Save save pid
-func (pid Pid) Save() error {
+// IsStale whether the pid file outlived its process: the file is unreadable, the process is gone,
+// or (on Linux) the pid was reused by another program. a missing file is not stale
+func (pid Pid) IsStale() bool {
+	number, err := pid.Read()
+	if err != nil {
+		return !os.IsNotExist(err)
+	}
+	return !alive(number) || pid.verify(number) != nil
+}
+
+// Save save pid, the file stays open and locked until Remove
+func (pid *Pid) Save() error {
+	if pid.Store != nil {
+		return pid.saveRecord()
+	}
 	var err error
-	pid.File, err = write(pid.SaveFilename(), strconv.Itoa(pid.Pid))
+	mode := pid.DirMode
+	if mode == 0 {
+		mode = DefaultPidDirMode
+	}
+	pid.File, err = write(pid.SaveFilename(), strconv.Itoa(pid.Pid), mode)
 	return err
 }
 
 // Remove Close the file descriptor and delete the pid file
-func (pid Pid) Remove() {
-	_ = pid.File.Close()
-	_ = os.Remove(pid.SaveFilename())
+func (pid *Pid) Remove() {
+	if pid.File != nil {
+		_ = pid.File.Close()
+	}
+	_ = pid.forget(pid.SaveFilename())
+}
+
+// openDir keep the pid directory open, so that its files can be removed after a chroot
+func (pid *Pid) openDir() error {
+	dir, err := os.Open(filepath.Dir(pid.SaveFilename()))
+	if err != nil {
+		return err
+	}
+	pid.dir = dir
+	return nil
+}
+
+// remove delete filename, a file of the pid directory
+func (pid *Pid) remove(filename string) error {
+	if pid.dir != nil && filepath.Dir(filename) == filepath.Dir(pid.SaveFilename()) {
+		return unlinkat(pid.dir, filepath.Base(filename))
+	}
+	return os.Remove(filename)
 }
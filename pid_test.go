@@ -0,0 +1,124 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// BenchmarkSaveFilename the cost of the pid file name, computed by every command and on every restart
+func BenchmarkSaveFilename(b *testing.B) {
+	pid := (&Pid{ServicesName: "bench"}).SetSavePath("run")
+	for i := 0; i < b.N; i++ {
+		_ = pid.SaveFilename()
+	}
+}
+
+func TestPidExpand(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		index   string
+		want    string
+	}{
+		{"", "http", "0", "http.pid"},
+		{"", "http-2", "2", "http-2.pid"},
+		{"%s.lock", "http", "0", "http.lock"},
+		{"app-%d.pid", "http-3", "3", "app-3.pid"},
+		{"%s/%d/%s.pid", "http-1", "1", "http-1/1/http-1.pid"},
+		{"app.pid", "http", "0", "app.pid"},
+	}
+	for _, test := range tests {
+		pid := Pid{Filename: test.pattern}
+		if got := pid.expand(test.name, test.index); got != test.want {
+			t.Errorf("%q expand(%q, %q) = %q, want %q", test.pattern, test.name, test.index, got, test.want)
+		}
+	}
+}
+
+func TestInstanceIndex(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		filename string
+		index    int
+		ok       bool
+	}{
+		{"", "http-3.pid", 3, true},
+		{"", "http.pid", 0, false},
+		{"", "http-x.pid", 0, false},
+		{"", "https-1.pid", 0, false},
+		{"", "http-1.pid.tmp", 0, false},
+		{"app-%d.pid", "app-12.pid", 12, true},
+		{"app-%d.pid", "app.pid", 0, false},
+		{"%s.%d.pid", "http-2.2.pid", 2, true},
+		{"%s.%d.pid", "http-2.3.pid", 0, false},
+	}
+	for _, test := range tests {
+		process := NewProcess(testWorker{dir: "/var/run", name: "http"})
+		process.pid.SetFilename(test.pattern)
+		index, ok := process.instanceIndex(filepath.Join(process.pid.SavePath, test.filename))
+		if ok != test.ok || ok && index != test.index {
+			t.Errorf("%q instanceIndex(%q) = %d, %v, want %d, %v", test.pattern, test.filename, index, ok, test.index, test.ok)
+		}
+	}
+}
+
+func TestPidVerify(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "freebsd" {
+		t.Skip("the executable of a process is not read on " + runtime.GOOS)
+	}
+	sleep, err := exec.LookPath("sleep")
+	if err == nil {
+		sleep, err = filepath.EvalSymlinks(sleep)
+	}
+	if err != nil {
+		t.Skip(err)
+	}
+	other := exec.Command(sleep, "10")
+	if err = other.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = other.Process.Kill()
+		_ = other.Wait()
+	}()
+	dir, err := ioutil.TempDir("", "verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name   string
+		pid    int
+		status *StatusFile // recorded next to the pid file
+		stale  bool
+	}{
+		{"this binary", os.Getpid(), nil, false},
+		{"reused pid", other.Process.Pid, nil, true},
+		{"binary recorded at start", other.Process.Pid, &StatusFile{Pid: other.Process.Pid, Executable: sleep}, false},
+		{"binary of another pid", other.Process.Pid, &StatusFile{Pid: os.Getpid(), Executable: sleep}, true},
+		{"other binary recorded", other.Process.Pid, &StatusFile{Pid: other.Process.Pid, Executable: "/usr/bin/true"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pid := (&Pid{ServicesName: "verify"}).SetSavePath(dir)
+			_ = os.Remove(pid.statusFilename())
+			if test.status != nil {
+				body, _ := json.Marshal(test.status)
+				if err := ioutil.WriteFile(pid.statusFilename(), body, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := pid.verify(test.pid)
+			if stale := errors.Is(err, ErrStalePid); stale != test.stale || !stale && err != nil {
+				t.Errorf("verify(%d) = %v, stale %v", test.pid, err, test.stale)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package daemon
+
+import (
+	"fmt"
+	"path/filepath"
+	"plugin"
+)
+
+// PluginSymbol the symbol a worker plugin must export: func NewWorker() daemon.Worker
+const PluginSymbol = "NewWorker"
+
+// LoadPlugins open every .so file of dir and add the worker returned by its NewWorker function as a child command,
+// so the services of a host binary can be deployed independently. plugins are supported where the Go plugin package is
+func (daemon *Daemon) LoadPlugins(dir string, options ...CommandOption) error {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.so"))
+	if err != nil {
+		return err
+	}
+
+	for _, filename := range filenames {
+		plug, err := plugin.Open(filename)
+		if err != nil {
+			return fmt.Errorf("open plugin %s: %v", filename, err)
+		}
+		symbol, err := plug.Lookup(PluginSymbol)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %v", filename, err)
+		}
+		newWorker, ok := symbol.(func() Worker)
+		if !ok {
+			return fmt.Errorf("plugin %s: %s is %T, want func() daemon.Worker", filename, PluginSymbol, symbol)
+		}
+		daemon.AddWorker(NewProcess(newWorker()), options...)
+	}
+	return nil
+}
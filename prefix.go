@@ -0,0 +1,43 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// PrefixWriter wrap output so that every line written to it starts with an RFC3339 timestamp and name, such as
+// "2006-01-02T15:04:05+07:00 [http] listening". several workers can share one log file and still be told apart:
+// proc.SetOutput(daemon.PrefixWriter(log, "http"), daemon.PrefixWriter(log, "http")).
+// a line is written at once when complete, the output is still reopened on SIGHUP and followed by the logs command
+func PrefixWriter(output io.Writer, name string) io.Writer {
+	return &prefixWriter{
+		lineWriter: lineWriter{emit: func(line []byte) {
+			_, _ = fmt.Fprintf(output, "%s [%s] %s\n", time.Now().Format(time.RFC3339), name, line)
+		}},
+		output: output,
+	}
+}
+
+// prefixWriter the writer of PrefixWriter
+type prefixWriter struct {
+	lineWriter
+	output io.Writer
+}
+
+// Reopen reopen the wrapped output when it supports it
+func (writer *prefixWriter) Reopen() error {
+	if output, ok := writer.output.(reopener); ok {
+		return output.Reopen()
+	}
+	return nil
+}
+
+// Name the file of the wrapped output, empty when it is not a file or a standard one
+func (writer *prefixWriter) Name() string {
+	if output, ok := writer.output.(namer); ok && writer.output != os.Stdout && writer.output != os.Stderr {
+		return output.Name()
+	}
+	return ""
+}
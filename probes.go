@@ -0,0 +1,69 @@
+package daemon
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// EnableProbes serve /healthz and /readyz from the child for the liveness and readiness probes of Kubernetes, on address
+// such as ":8086", or on the unix socket path with "unix:path". the listener is handed to the new child on restart
+func (process *Process) EnableProbes(address string) *Process {
+	return process.configure("EnableProbes", func() {
+		process.probesAddress = address
+	})
+}
+
+// ProbesHandler serve /healthz and /readyz, to be mounted on an existing HTTP server.
+// /healthz runs the check of a HealthChecker, and always passes without one. /readyz passes once the worker is ready,
+// and fails again as soon as it is stopping, so that no new connection is routed to it
+func (process *Process) ProbesHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(writer http.ResponseWriter, request *http.Request) {
+		if checker, ok := process.impl.(HealthChecker); ok {
+			if err := process.healthCheck.healthy(checker); err != nil {
+				http.Error(writer, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		_, _ = writer.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(writer http.ResponseWriter, request *http.Request) {
+		switch {
+		case atomic.LoadInt32(&process.terminating) != 0:
+			http.Error(writer, "stopping", http.StatusServiceUnavailable)
+		case atomic.LoadInt32(&process.isReady) == 0:
+			http.Error(writer, "not ready", http.StatusServiceUnavailable)
+		default:
+			_, _ = writer.Write([]byte("ok\n"))
+		}
+	})
+	return mux
+}
+
+// serveProbes in the child, serve the probes if enabled
+func (process *Process) serveProbes() error {
+	if process.probesAddress == "" {
+		return nil
+	}
+	var listener net.Listener
+	var err error
+	if path := strings.TrimPrefix(process.probesAddress, "unix:"); path != process.probesAddress {
+		listener, err = listen("unix", path, func(network, address string) (net.Listener, error) {
+			// the socket left by a process that did not remove it
+			_ = os.Remove(address)
+			return net.Listen(network, address)
+		})
+	} else {
+		listener, err = Listen("tcp", process.probesAddress)
+	}
+	if err != nil {
+		return err
+	}
+	go func() {
+		_ = http.Serve(listener, process.ProbesHandler())
+	}()
+	return nil
+}
@@ -0,0 +1,32 @@
+//go:build darwin || freebsd
+// +build darwin freebsd
+
+package daemon
+
+import "path/filepath"
+
+// descendants child processes are only listed on Linux
+func descendants(pid int) []int {
+	return nil
+}
+
+// sameBinary whether process pid runs this binary, so that a pid reused by an unrelated process is not mistaken for ours
+func sameBinary(pid int) bool {
+	path, err := processExecutable(pid)
+	if err != nil {
+		// gone, or not readable: nothing proves it is another program
+		return true
+	}
+	return samePath(path, executable())
+}
+
+// samePath whether the paths name the same file once their symbolic links are resolved
+func samePath(a, b string) bool {
+	if resolved, err := filepath.EvalSymlinks(a); err == nil {
+		a = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(b); err == nil {
+		b = resolved
+	}
+	return a == b
+}
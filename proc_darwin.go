@@ -0,0 +1,25 @@
+package daemon
+
+import (
+	"bytes"
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// processExecutable the path of the binary process pid runs, from the arguments the kernel keeps for it
+func processExecutable(pid int) (string, error) {
+	args, err := unix.SysctlRaw("kern.procargs2", pid)
+	if err != nil {
+		return "", err
+	}
+	// argc, then the path the binary was executed with
+	if len(args) < 4 {
+		return "", errors.New("invalid kern.procargs2")
+	}
+	path := args[4:]
+	if end := bytes.IndexByte(path, 0); end >= 0 {
+		path = path[:end]
+	}
+	return string(path), nil
+}
@@ -0,0 +1,16 @@
+package daemon
+
+import (
+	"bytes"
+
+	"golang.org/x/sys/unix"
+)
+
+// processExecutable the path of the binary process pid runs
+func processExecutable(pid int) (string, error) {
+	path, err := unix.SysctlRaw("kern.proc.pathname", pid)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight(path, "\x00")), nil
+}
@@ -0,0 +1,81 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procStat the fields of /proc/<pid>/stat that are used
+type procStat struct {
+	state  byte // R running, S sleeping, Z zombie...
+	parent int
+}
+
+// processes every process of the system, read from /proc
+func processes() map[int]procStat {
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	table := make(map[int]procStat)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := ioutil.ReadFile("/proc/" + entry.Name() + "/stat")
+		if err != nil {
+			continue
+		}
+		// the command name is in parentheses and may contain spaces, the fields after it are well formed
+		fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+		if len(fields) < 2 {
+			continue
+		}
+		if parent, err := strconv.Atoi(fields[1]); err == nil {
+			table[pid] = procStat{state: fields[0][0], parent: parent}
+		}
+	}
+	return table
+}
+
+// descendants the pids of every process below pid, read from /proc
+func descendants(pid int) []int {
+	children := make(map[int][]int)
+	for child, stat := range processes() {
+		children[stat.parent] = append(children[stat.parent], child)
+	}
+
+	var result []int
+	queue := children[pid]
+	for len(queue) > 0 {
+		result = append(result, queue[0])
+		queue = append(queue[1:], children[queue[0]]...)
+	}
+	return result
+}
+
+// processExecutable the path of the binary process pid runs
+func processExecutable(pid int) (string, error) {
+	return os.Readlink("/proc/" + strconv.Itoa(pid) + "/exe")
+}
+
+// sameBinary whether process pid runs this binary, so that a pid reused by an unrelated process is not mistaken for ours.
+// the executable link needs the permission to trace the process, the command line is readable by everybody
+func sameBinary(pid int) bool {
+	if path, err := processExecutable(pid); err == nil {
+		return strings.TrimSuffix(path, " (deleted)") == executable()
+	}
+
+	cmdline, err := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/cmdline")
+	if err != nil {
+		// gone, or not readable: nothing proves it is another program
+		return true
+	}
+	argv0 := strings.SplitN(string(cmdline), "\x00", 2)[0]
+	return filepath.Base(argv0) == filepath.Base(executable())
+}
@@ -0,0 +1,21 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package daemon
+
+import "errors"
+
+// descendants child processes are only listed on Linux
+func descendants(pid int) []int {
+	return nil
+}
+
+// processExecutable the binary of another process is only read on Linux, macOS and FreeBSD
+func processExecutable(pid int) (string, error) {
+	return "", errors.New("reading the executable of a process is not supported on this system")
+}
+
+// sameBinary the binary of another process cannot be checked, it is assumed to be ours
+func sameBinary(pid int) bool {
+	return true
+}
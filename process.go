@@ -2,15 +2,30 @@ package daemon
 
 import (
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"os/exec"
-	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
 )
 
 const (
 	// EnvName Identify the name of the environment variable that is the child process.
 	// A simple method is to set an environment variable so that the program can determine whether it is created by its own parent process after getting it.
 	EnvName = "DAEMON"
+
+	// ExitNotRunning LSB exit code of stop and status when the program is not running
+	ExitNotRunning = 3
+
+	// DefaultStopTimeout how long the default signal handlers wait for worker.Stop and worker.Restart
+	DefaultStopTimeout = 30 * time.Second
 )
 
 // Worker The interface that the working program must implement
@@ -27,146 +42,682 @@ type Worker interface {
 	Restart() error
 }
 
+// Validator If the worker implements this interface, Validate is executed by the start and restart commands before
+// anything is started or signaled, check config parses, ports are free, license valid... so that failures are reported
+// on the terminal instead of dying in the background. restarts and upgrades of the running worker don't run it
+type Validator interface {
+	Validate() error
+}
+
+// ValidationError returned by the start and restart commands when the Validator of the worker fails
+type ValidationError struct {
+	Name string
+	Err  error
+}
+
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("%s: validation failed: %v", err.Name, err.Err)
+}
+
+// Arguments If the worker implements this interface, SetArgs receives the arguments passed after "--" before Start is executed,
+// so workers don't need to register every flag with cobra.
+type Arguments interface {
+	SetArgs(args []string)
+}
+
 type (
 	// system signal handlers
-	signalHandlers map[os.Signal]func()
+	signalHandlers map[os.Signal][]signalHandler
 	// Process a service process info
 	Process struct {
-		Pipeline       [3]*os.File // input/output pipe, 0->input, 1->output, 2->err
-		Pid            *Pid        // pid pid info
-		worker         Worker      // worker
-		DaemonTag      string
-		SignalHandlers signalHandlers // signal handlers
+		pipeline  [3]*os.File    // input/output pipe, 0->input, 1->output, 2->err
+		pid       *Pid           // pid pid info
+		worker    Worker         // worker
+		impl      interface{}    // the worker given by the user, optional interfaces are looked up on it
+		daemonTag string         // see SetDaemonTag
+		handlers  signalHandlers // signal handlers
+		signals   *dispatcher    // subscribes to the signals of handlers
+
+		commands  []*cobra.Command    // commands handed to the worker
+		verbNames map[string]string   // names of the generated commands after RenameCommand
+		flags     map[string][]string // flags parsed by the parent
+		args      []string            // arguments after "--"
+
+		confirmation  bool            // ask before destructive operations
+		validated     bool            // the Validator passed, the command does not run it twice
+		stopTimeout   time.Duration   // deadline of worker.Stop/worker.Restart in the default handlers
+		artifacts     []string        // files that belong to a running instance
+		lsb           bool            // exit with LSB codes instead of 0 when not running
+		queueEnabled  bool            // generate the enqueue command
+		queue         *Queue          // durable job queue
+		locks         []*ResourceLock // shared resource locks
+		locksMutex    sync.Mutex
+		bus           *Bus                   // pub/sub bus shared with co-hosted workers
+		events        events                 // the channel of Events
+		scripts       map[ScriptEvent]string // external scripts run on lifecycle events
+		chaos         *Chaos                 // failures injected in chaos mode
+		terminating   int32                  // set once a termination signal is received
+		supervision   RestartMode            // restart the worker when it exits
+		restartPolicy RestartPolicy          // backoff of supervised restarts
+		killChildren  bool                   // kill the processes of the worker when stop times out
+		outputs       [2]io.Writer           // stdout and stderr of the worker in the child
+		log           Logger                 // logger of the lifecycle events
+		defaultLog    writerLogger           // used without a logger
+
+		foreground      bool             // the worker runs in the process of the start command
+		generation      int32            // incremented by restarts in the foreground, see run
+		daemonize       DaemonizeOptions // how the child is detached from the start command
+		umaskSet        bool             // apply the umask of daemonize even when it is zero
+		startDir        string           // working directory the child was started in, before Chdir
+		credentials     *credentials     // account the worker runs as
+		readyOnce       sync.Once        // the worker reported it is ready
+		healthCheck     HealthCheck      // polling of a HealthChecker
+		started         time.Time        // when the child started
+		isReady         int32            // set once the worker is ready
+		readyDropped    bool             // chaos mode decided the worker never becomes ready
+		unhealthy       int32            // set while the health check fails
+		history         signalHistory    // the last signals handled, for Metrics and Stats
+		metricsAddress  string           // serve the metrics from the child
+		probesAddress   string           // serve the probes from the child, see EnableProbes
+		spawned         int              // pid of the process started by Run in the parent
+		controlEnabled  bool             // listen on the control socket
+		controlListener net.Listener     // the control socket in the child
+		controlAuth     ControlAuth      // who may send control requests
+		bridgeEnabled   bool             // exchange the messages of the bus with the other workers, see BridgeBus
+		busListener     net.Listener     // the bus socket in the child
+
+		crashHandler func(recovered interface{}, stack []byte) // called when worker.Start panics
+		startWait    time.Duration                             // how long the start command waits for the child, see waitStartup
+		startup      *os.File                                  // the pipe the child reports its startup on
+		startupMutex sync.Mutex
+		env          map[string]string // set in the environment of the child
+		clearEnv     bool              // the child does not inherit the environment
+		keepEnv      []string          // inherited despite clearEnv
+
+		chroot        string         // root directory of the worker
+		landlockRules []LandlockRule // files the child can access, see restrict
+		rlimits       []rlimit       // resource limits of the child
+		cgroup        *Cgroup        // cgroup of the child
+		title         *string        // title of the child set by SetTitle
+		logPaths      [2]string      // files of the standard output and error, see SetLogFiles
+
+		oneShot   bool // the worker runs to completion
+		subreaper bool // adopt and reap the orphans of the worker
+		asInit    bool // the worker runs in the init process of a container, see RunAsInit
+		killGroup bool // the worker leads a process group, killed when stop times out
+
+		stopSignal    os.Signal    // sent by the stop command, SIGUSR1 by default
+		restartSignal os.Signal    // sent by the restart command, SIGUSR2 by default
+		upgradeSignal os.Signal    // sent by the upgrade command, SIGWINCH by default
+		readyProbe    *ReadyProbe  // polled by start --wait-ready
+		hooks         []Hooks      // see AddHooks
+		webhooks      []webhook    // see SetWebhook
+		watched       []string     // the files of EnableWatch, the binary first
+		instances     int          // see SetInstances
+		reason        atomic.Value // why the next restart happens, see requestRestart
+		selfUpdate    *SelfUpdate  // see EnableSelfUpdate
+
+		configMutex sync.Mutex // guards the setters against Run, see configure
+		frozen      bool       // set by Run, the setters are ignored
+		configErr   error      // the first setter called once frozen
 	}
 )
 
-// Listen listen all system signals
-func (handlers signalHandlers) Listen() {
-	var sig = make(chan os.Signal)
-	signal.Notify(sig)
-	for {
-		received := <-sig
-		if handler, ok := handlers[received]; ok {
-			handler()
+var (
+	executablePath string
+	executableOnce sync.Once
+)
+
+// executable the path of the running binary, resolved once so that re-exec does not depend on $PATH or the working directory
+func executable() string {
+	executableOnce.Do(func() {
+		path, err := os.Executable()
+		if err != nil {
+			path = os.Args[0]
 		}
+		executablePath = path
+	})
+	return executablePath
+}
+
+// absolute resolve path against the working directory once, instead of on every use
+func absolute(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
 	}
+	return path
 }
 
-// NewProcess create a process instance with Worker
-func NewProcess(worker Worker) *Process {
+// NewProcess create a process instance with Worker, configured by options
+func NewProcess(worker Worker, options ...ProcessOption) *Process {
 	process := &Process{
-		Pipeline: [3]*os.File{os.Stdin, os.Stdout, os.Stderr},
-		Pid: &Pid{
+		pipeline: [3]*os.File{os.Stdin, os.Stdout, os.Stderr},
+		pid: &Pid{
 			ServicesName: worker.Name(),
-			SavePath:     worker.PidSavePath(),
+			SavePath:     absolute(worker.PidSavePath()),
 			Pid:          os.Getpid(),
 		},
-		worker:    worker,
-		DaemonTag: EnvName,
+		worker:        worker,
+		impl:          worker,
+		verbNames:     make(map[string]string),
+		daemonTag:     EnvName,
+		stopTimeout:   DefaultStopTimeout,
+		restartPolicy: DefaultRestartPolicy,
+		healthCheck:   DefaultHealthCheck,
+		handlers:      make(signalHandlers),
+		stopSignal:    SIGUSR1,
+		restartSignal: SIGUSR2,
+		upgradeSignal: SIGWINCH,
 	}
+	process.signals = &dispatcher{handlers: process.handlers}
+	process.defaultLog.output = process.stdout
 	process.registerDefaultInterruptHandle()
+	process.registerDefaultTerminateHandle()
 	process.registerDefaultStopHandle()
 	process.registerDefaultRestartHandle()
+	process.registerDefaultUpgradeHandle()
+	process.registerDefaultHangupHandle()
+	for _, option := range options {
+		option(process)
+	}
 	return process
 }
 
 // SetPipeline set standard i/o pipeline, 0 -> stdin(generally give up directly, you can send nil), 1 -> stdout, 2 -> stderr
 // of course, you can choose not to set it.
 func (process *Process) SetPipeline(pipes ...*os.File) *Process {
-	if len(pipes) > 3 {
-		pipes = pipes[0:3]
-	}
-	for index, pipe := range pipes {
-		process.Pipeline[index] = pipe
-	}
-	return process
+	return process.configure("SetPipeline", func() {
+		if len(pipes) > 3 {
+			pipes = pipes[0:3]
+		}
+		for index, pipe := range pipes {
+			process.pipeline[index] = pipe
+		}
+	})
 }
 
 // SetDaemonTag custom DAEMON env name
 func (process *Process) SetDaemonTag(name string) *Process {
-	process.DaemonTag = name
-	return process
+	return process.configure("SetDaemonTag", func() {
+		process.daemonTag = name
+	})
+}
+
+// PidFile the pid file of the worker
+func (process *Process) PidFile() *Pid {
+	return process.pid
+}
+
+// SetStopTimeout how long the default handlers wait for worker.Stop and worker.Restart,
+// after that the pid file is cleaned up and the process exits anyway. zero or negative waits forever
+func (process *Process) SetStopTimeout(timeout time.Duration) *Process {
+	return process.configure("SetStopTimeout", func() {
+		process.stopTimeout = timeout
+	})
+}
+
+// SetLSBExitCodes stop on a program that is not running exits with ExitNotRunning instead of 0, for LSB strictness
+func (process *Process) SetLSBExitCodes(strict bool) *Process {
+	return process.configure("SetLSBExitCodes", func() {
+		process.lsb = strict
+	})
+}
+
+// within run fn of the named phase, giving up after the stop timeout so that the process never wedges on shutdown
+func (process *Process) within(phase string, fn func() error) error {
+	if process.stopTimeout <= 0 {
+		return fn()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(process.stopTimeout):
+		return fmt.Errorf("%s: graceful %s %w after %s", process.worker.Name(), phase, ErrTimeout, process.stopTimeout)
+	}
+}
+
+// SetKillChildren kill the processes started by the worker when the graceful stop times out, so they don't outlive it (Linux only)
+func (process *Process) SetKillChildren(kill bool) *Process {
+	return process.configure("SetKillChildren", func() {
+		process.killChildren = kill
+	})
+}
+
+// SetKillGroup run the worker as the leader of its own process group, which the processes it starts join,
+// and kill the whole group when the graceful stop times out, so that no ffmpeg or shell script survives a stop.
+// the group is killed once the pid file is removed, the worker included. no effect on Windows
+func (process *Process) SetKillGroup(kill bool) *Process {
+	return process.configure("SetKillGroup", func() {
+		process.killGroup = kill
+	})
+}
+
+// killDescendants kill every process below this one
+func (process *Process) killDescendants() {
+	for _, pid := range descendants(os.Getpid()) {
+		if child, err := os.FindProcess(pid); err == nil {
+			process.info("killing child process", "pid", pid)
+			_ = child.Kill()
+		}
+	}
+}
+
+// shutdown stop the worker, clean up the pid file and exit
+func (process *Process) shutdown() {
+	process.info("stopping")
+	process.publish(Event{Type: EventStopping})
+	process.notify("STOPPING=1")
+	process.injectStopDelay()
+	process.hookPreStop()
+	err := process.within("stop", process.worker.Stop)
+	if err != nil {
+		process.error("stop failed", "err", err)
+		if process.killChildren {
+			process.killDescendants()
+		}
+	}
+	process.hookPostStop()
+	if err := process.saveState(); err != nil {
+		process.error("save state failed", "err", err)
+	}
+	process.unlockAll()
+	process.closeControl()
+	process.emit(OnStop, "")
+	process.removePid()
+	process.info("stopped")
+	process.publish(Event{Type: EventStopped})
+	if process.oneShot {
+		process.reportExit(0)
+	}
+	process.hookExit(0)
+	if err != nil && process.killGroup {
+		process.info("killing process group", "pgid", os.Getpid())
+		_ = signalGroup(os.Getpid(), syscall.SIGKILL)
+	}
+	os.Exit(0)
 }
 
 // On register the signal handling method of the custom child process. The method registered here is actually running on the child process.
-// The real program logic runs in a co-program of the child process, and the signal monitoring method of the main co-program running of the child process
+// The real program logic runs in a co-program of the child process, and the signal monitoring method of the main co-program running of the child process.
+// the handlers of a signal run in registration order, before the default handler, which usually ends the process
 func (process *Process) On(signal os.Signal, fn func()) {
-	if process.SignalHandlers == nil {
-		process.SignalHandlers = make(signalHandlers)
-	}
-	process.SignalHandlers[signal] = fn
+	process.signals.add(signal, signalHandler{fn: fn}, false)
+}
+
+// OnOnce like On, but fn only runs for the first signal received
+func (process *Process) OnOnce(signal os.Signal, fn func()) {
+	process.signals.add(signal, signalHandler{fn: fn, once: true}, false)
+}
+
+// ReplaceHandler make fn the only handler of signal, the default handler included
+func (process *Process) ReplaceHandler(signal os.Signal, fn func()) {
+	process.signals.add(signal, signalHandler{fn: fn}, true)
+}
+
+// onDefault register a default handler
+func (process *Process) onDefault(signal os.Signal, fn func()) {
+	process.signals.add(signal, signalHandler{fn: fn, fallback: true}, false)
+}
+
+// Off deregister the handler of signal, including a default one such as Off(syscall.SIGHUP).
+// the process stops subscribing to it, so it gets its default behaviour back unless another package handles it
+func (process *Process) Off(signal os.Signal) {
+	process.signals.remove(signal)
+}
+
+// SetStopSignal stop the worker on sig instead of SIGUSR1, which Go's runtime debugging or other libraries may use.
+// the stop command sends it and the default stop handler moves to it, the handlers registered with On stay where they are
+func (process *Process) SetStopSignal(sig os.Signal) *Process {
+	return process.configure("SetStopSignal", func() {
+		process.signals.removeDefaults(process.stopSignal)
+		process.stopSignal = sig
+		process.signals.mutex.Lock()
+		process.signals.terminations = terminations(sig)
+		process.signals.mutex.Unlock()
+		process.registerDefaultStopHandle()
+	})
+}
+
+// SetRestartSignal restart the worker on sig instead of SIGUSR2, see SetStopSignal
+func (process *Process) SetRestartSignal(sig os.Signal) *Process {
+	return process.configure("SetRestartSignal", func() {
+		process.signals.removeDefaults(process.restartSignal)
+		process.restartSignal = sig
+		process.registerDefaultRestartHandle()
+	})
 }
 
 // monitor interrupt signal operation
 func (process *Process) registerDefaultInterruptHandle() {
-	process.On(os.Interrupt, func() {
-		err := process.worker.Stop()
-		if err != nil {
-			_, _ = process.Pipeline[1].WriteString(err.Error())
-		}
-		process.Pid.Remove()
-		os.Exit(0)
-	})
+	process.onDefault(os.Interrupt, process.shutdown)
+}
+
+// stop on TERM signals, as sent by init systems and container runtimes
+func (process *Process) registerDefaultTerminateHandle() {
+	process.onDefault(syscall.SIGTERM, process.shutdown)
 }
 
-// register the default stop method and listen for USR1 signals
+// register the default stop method and listen for the stop signal, USR1 by default
 func (process *Process) registerDefaultStopHandle() {
-	process.On(SIGUSR1, func() {
-		err := process.worker.Stop()
-		if err != nil {
-			_, _ = process.Pipeline[1].WriteString(err.Error())
-		}
-		process.Pid.Remove()
-		os.Exit(0)
-	})
+	process.onDefault(process.stopSignal, process.shutdown)
 }
 
-// register the default restart method and listen for USR2 signals
+// register the default restart method and listen for the restart signal, USR2 by default
 func (process *Process) registerDefaultRestartHandle() {
-	process.On(SIGUSR2, func() {
-		process.Pid.Remove()
-		var done = make(chan bool)
-		go func() {
-			err := process.worker.Restart()
+	process.onDefault(process.restartSignal, func() {
+		if process.foreground {
+			process.restartInPlace()
+			return
+		}
+		process.info("restart triggered")
+		process.publish(Event{Type: EventRestartScheduled, Reason: process.restartReason()})
+		process.emit(OnRestart, "")
+		process.hookPreRestart()
+		if err := process.saveState(); err != nil {
+			process.error("save state failed", "err", err)
+		}
+		process.removePid()
+		process.closeControl()
+		// with the listeners of Listen, the new child is started first and inherits them before the old worker closes
+		// them while draining. otherwise the old worker drains while the new child starts, as before
+		handover := listening()
+		done := make(chan struct{})
+		drain := func() {
+			if err := process.within("restart", process.drain); err != nil {
+				process.error("restart failed", "err", err)
+			}
+			close(done)
+		}
+		if !handover {
+			go drain()
+		}
+		// a stop received while restarting wins, no new child is started
+		if atomic.LoadInt32(&process.terminating) == 0 {
+			_ = os.Unsetenv(process.daemonTag)
+			_ = os.Setenv(process.restartsEnv(), strconv.Itoa(process.restarts()+1))
+			process.passRestartReason()
+			err := process.Run()
 			if err != nil {
-				_, _ = process.Pipeline[1].WriteString(err.Error())
+				process.error("start new child failed", "err", err)
 			}
-			done <- true
-		}()
-		_ = os.Unsetenv(process.DaemonTag)
-		err := process.Run()
-		if err != nil {
-			_, _ = process.Pipeline[1].WriteString(err.Error())
+		}
+		if handover {
+			drain()
 		}
 		<-done
+		process.hookExit(0)
 		os.Exit(0)
 	})
 }
 
-// IsChild To determine whether it is started in a child process, according to the environment variable DAEMON
+// restartInPlace restart in the foreground, the binary is executed again in this process so that it keeps its pid
+func (process *Process) restartInPlace() {
+	process.info("restart triggered")
+	process.publish(Event{Type: EventRestartScheduled, Reason: process.restartReason()})
+	process.emit(OnRestart, "")
+	process.hookPreRestart()
+	if err := process.saveState(); err != nil {
+		process.error("save state failed", "err", err)
+	}
+	atomic.AddInt32(&process.generation, 1)
+	if err := process.within("restart", process.drain); err != nil {
+		process.error("restart failed", "err", err)
+	}
+	process.unlockAll()
+	process.closeControl()
+	process.removePid()
+	_ = os.Setenv(process.restartsEnv(), strconv.Itoa(process.restarts()+1))
+	process.passRestartReason()
+	if process.startDir != "" {
+		// the binary executed again resolves relative paths like the first time
+		_ = os.Chdir(process.startDir)
+	}
+	if err := reexec(); err != nil {
+		process.error("restart failed", "err", err)
+		process.hookExit(1)
+		os.Exit(1)
+	}
+}
+
+// start run the worker, a panic is reported by crash and ends the process
+func (process *Process) start() {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			process.crash(recovered)
+		}
+	}()
+	process.worker.Start()
+	process.startFailed()
+}
+
+// run start the worker, in the foreground or one-shot the process exits with it unless it was stopped or restarted meanwhile
+func (process *Process) run() {
+	generation := atomic.LoadInt32(&process.generation)
+	process.start()
+	if !process.completes(generation) {
+		return
+	}
+	code := process.exitCode()
+	process.info("worker exited", "code", code)
+	process.unlockAll()
+	process.closeControl()
+	process.emit(OnStop, "")
+	process.removePid()
+	process.publish(Event{Type: EventStopped, Code: code})
+	process.reportExit(code)
+	process.hookExit(code)
+	os.Exit(code)
+}
+
+// savePid save the pid file, unless a supervisor owns it
+func (process *Process) savePid() error {
+	if process.supervised() {
+		return nil
+	}
+	return process.pid.Save()
+}
+
+// removePid remove the pid file, unless a supervisor owns it
+func (process *Process) removePid() {
+	if !process.supervised() {
+		process.pid.Remove()
+	}
+	_ = process.pid.remove(process.statusFilename())
+}
+
+// verbName the name of the generated command of verb, which RenameCommand may have changed
+func (process *Process) verbName(verb string) string {
+	if name, ok := process.verbNames[verb]; ok {
+		return name
+	}
+	return verb
+}
+
+// validate run the Validator of the worker, once: restart runs it before it falls through to a fresh start
+func (process *Process) validate() error {
+	if validator, ok := process.impl.(Validator); ok && !process.validated {
+		if err := validator.Validate(); err != nil {
+			return &ValidationError{Name: process.worker.Name(), Err: err}
+		}
+	}
+	process.validated = true
+	return nil
+}
+
+// IsChild To determine whether it is started in a child process, according to the environment variable DAEMON.
+// in the foreground the process of the start command is the child
 func (process *Process) IsChild() bool {
-	return os.Getenv(process.DaemonTag) == "true"
+	return process.foreground || os.Getenv(process.daemonTag) == "true"
 }
 
 // Run Run the program, the main logic runs in the cooperative program, and the main cooperative program runs the system signal listener.
-func (process *Process) Run() error {
+func (process *Process) Run() (err error) {
+	process.freeze()
 	if process.IsChild() {
-		if err := process.Pid.Save(); err != nil {
+		process.joinInstance()
+		// a child that fails before it started tells the start command why
+		process.openStartup()
+		defer func() {
+			if err != nil {
+				process.reportStartup(err)
+			}
+		}()
+		if err := process.restrict(); err != nil {
+			return err
+		}
+		if process.intermediate() {
+			return process.forkAgain()
+		}
+		if process.foreground {
+			process.cleanup()
+			if err := process.redirectLogFiles(); err != nil {
+				return err
+			}
+		}
+		if err := process.joinCgroup(); err != nil {
 			return err
 		}
-		go process.worker.Start()
-		process.SignalHandlers.Listen()
+		if err := process.becomeSubreaper(); err != nil {
+			return err
+		}
+		if title := process.procTitle(process.role()); title != "" {
+			SetProcTitle(title)
+		}
+		if process.supervision != RestartNever && !process.supervised() {
+			return process.supervise()
+		}
+		if process.killGroup {
+			if err := leadGroup(); err != nil {
+				return err
+			}
+		}
+		process.forwardSignals()
+		if err := process.restoreFlags(); err != nil {
+			return err
+		}
+		if err := process.applyDaemonize(); err != nil {
+			return err
+		}
+		if err := process.applyRlimits(); err != nil {
+			return err
+		}
+		process.started = time.Now()
+		if err := process.savePid(); err != nil {
+			return err
+		}
+		if err := process.redirectOutput(); err != nil {
+			return err
+		}
+		process.info("pid saved", "pid", os.Getpid(), "file", process.pid.SaveFilename())
+		process.writeStatus()
+		if err := process.serveControl(); err != nil {
+			return err
+		}
+		if err := process.serveBus(); err != nil {
+			return err
+		}
+		if err := process.serveMetrics(); err != nil {
+			return err
+		}
+		if err := process.serveProbes(); err != nil {
+			return err
+		}
+		if err := process.dropPrivileges(); err != nil {
+			return err
+		}
+		if worker, ok := process.impl.(Arguments); ok {
+			worker.SetArgs(process.args)
+		}
+		process.dropReadiness()
+		notifier, notifies := process.impl.(ReadyNotifier)
+		if notifies {
+			notifier.SetReady(func() {
+				if process.ready() && !process.oneShot {
+					process.reportStartup(nil)
+				}
+			})
+		}
+		if err := process.restoreState(); err != nil {
+			return err
+		}
+		process.publish(Event{Type: EventStarting})
+		if err := process.hookPreStart(); err != nil {
+			process.removePid()
+			return err
+		}
+		go process.run()
+		process.info("started")
+		if !notifies {
+			process.awaitReady()
+		}
+		process.publish(Event{Type: EventStarted})
+		process.emit(OnStart, "")
+		process.hookPostStart()
+		process.injectRestarts()
+		process.keepHangup()
+		process.signals.dispatch(&process.terminating, func(received os.Signal) {
+			process.info("signal received", "signal", received)
+			process.signaled(received)
+			process.publish(Event{Type: EventSignalReceived, Signal: received})
+		})
 		return nil
 	}
 
-	cmd := exec.Command(os.Args[0], os.Args[1:]...)
-	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=true", process.DaemonTag))
-	cmd.Stdin, cmd.Stdout, cmd.Stderr = process.Pipeline[0], process.Pipeline[1], process.Pipeline[2]
+	process.cleanup()
+
+	cmd := exec.Command(executable(), os.Args[1:]...)
+	cmd.Args = titled(process.procTitle(process.role()), cmd.Args)
+	cmd.Env = append(process.environ(), fmt.Sprintf("%s=true", process.daemonTag), process.flagsEnviron())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = process.pipeline[0], process.pipeline[1], process.pipeline[2]
+	logFiles, err := process.openLogFiles()
+	if err != nil {
+		return err
+	}
+	if logFiles[0] != nil {
+		cmd.Stdout = logFiles[0]
+	}
+	if logFiles[1] != nil {
+		cmd.Stderr = logFiles[1]
+	}
+	defer func() {
+		for _, file := range logFiles {
+			if file != nil {
+				_ = file.Close()
+			}
+		}
+	}()
+	process.detach(cmd)
+
+	// on restart, hand the listeners to the new child
+	files, env, err := inheritable()
+	if err != nil {
+		return err
+	}
+	if files != nil {
+		cmd.ExtraFiles = files
+		cmd.Env = append(cmd.Env, env)
+	}
+	if process.startWait > 0 {
+		if err = process.awaitStartup(cmd); err != nil {
+			return err
+		}
+		// the write end belongs to the child
+		files = append(files, cmd.ExtraFiles[len(files):]...)
+	}
 
-	err := cmd.Start()
+	err = cmd.Start()
+	for _, file := range files {
+		_ = file.Close()
+	}
 	if err != nil {
 		return err
 	}
+	process.spawned = cmd.Process.Pid
 	return cmd.Process.Release()
 
 }
@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+// testWorker a worker that does nothing, saving its pid file in dir
+type testWorker struct {
+	dir  string
+	name string
+}
+
+func (worker testWorker) PidSavePath() string { return worker.dir }
+func (worker testWorker) Name() string        { return worker.name }
+func (worker testWorker) Start()              {}
+func (worker testWorker) Stop() error         { return nil }
+func (worker testWorker) Restart() error      { return nil }
+
+// discardLogger drops the records
+type discardLogger struct{}
+
+func (discardLogger) Info(string, ...interface{})  {}
+func (discardLogger) Error(string, ...interface{}) {}
+
+// lookSleep the path of the sleep binary, whose processes stand for running workers. skips where the executable
+// of a process, which pid files are verified against, is not read
+func lookSleep(t *testing.T) string {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "freebsd" {
+		t.Skip("the executable of a process is not read on " + runtime.GOOS)
+	}
+	sleep, err := exec.LookPath("sleep")
+	if err == nil {
+		sleep, err = filepath.EvalSymlinks(sleep)
+	}
+	if err != nil {
+		t.Skip(err)
+	}
+	return sleep
+}
+
+// runPid start command as the process of the worker saving its pid file in dir, reaped once it exits
+func runPid(t *testing.T, dir, name string, command ...string) *exec.Cmd {
+	cmd := exec.Command(command[0], command[1:]...)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = cmd.Wait() }()
+	pid := &Pid{ServicesName: name, SavePath: dir}
+	if err := ioutil.WriteFile(pid.SaveFilename(), []byte(strconv.Itoa(cmd.Process.Pid)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// stop only signals a process of another binary recorded in the status file
+	status, _ := json.Marshal(StatusFile{Pid: cmd.Process.Pid, Executable: command[0]})
+	if err := ioutil.WriteFile(pid.statusFilename(), status, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return cmd
+}
@@ -0,0 +1,44 @@
+package daemon
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// SetProcTitle the title ps shows for the process instead of its command line, such as "myapp: worker http".
+// it takes the room of the original command line and is truncated to it, the name of the process (ps -o comm)
+// to 15 bytes. only implemented on Linux
+func SetProcTitle(title string) {
+	setProcTitle(title)
+}
+
+// SetTitle the title of the child instead of "<binary>: worker <name>", empty keeps the command line
+func (process *Process) SetTitle(title string) *Process {
+	return process.configure("SetTitle", func() {
+		process.title = &title
+	})
+}
+
+// role what the child of the process does, a supervisor when the worker runs in a process of its own
+func (process *Process) role() string {
+	if process.supervision != RestartNever && !process.supervised() {
+		return "supervisor"
+	}
+	return "worker"
+}
+
+// procTitle the title of a child in role
+func (process *Process) procTitle(role string) string {
+	if process.title != nil {
+		return *process.title
+	}
+	return fmt.Sprintf("%s: %s %s", filepath.Base(executable()), role, process.worker.Name())
+}
+
+// titled the arguments of a child started with title, the first one gives SetProcTitle room for the title
+func titled(title string, args []string) []string {
+	if title == "" || len(args) == 0 {
+		return args
+	}
+	return append([]string{title}, args[1:]...)
+}
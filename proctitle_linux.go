@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"unsafe"
+)
+
+var argv struct {
+	once sync.Once
+	area []byte
+}
+
+// stringData the bytes of s, the first word of a string
+func stringData(s string) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&s))
+}
+
+// argvArea the memory of the command line the kernel shows in /proc/<pid>/cmdline, nil if the arguments are not where
+// the kernel put them. os.Args point into it and are copied out first
+func argvArea() []byte {
+	argv.once.Do(func() {
+		if len(os.Args) == 0 {
+			return
+		}
+		// the arguments follow each other, each one terminated by a NUL
+		for i := 1; i < len(os.Args); i++ {
+			if uintptr(stringData(os.Args[i])) != uintptr(stringData(os.Args[i-1]))+uintptr(len(os.Args[i-1])+1) {
+				return
+			}
+		}
+		last := os.Args[len(os.Args)-1]
+		start := stringData(os.Args[0])
+		size := uintptr(stringData(last)) + uintptr(len(last)) - uintptr(start)
+
+		args := make([]string, len(os.Args))
+		for i, arg := range os.Args {
+			args[i] = string([]byte(arg))
+		}
+		os.Args = args
+		argv.area = (*[1 << 30]byte)(start)[:size:size]
+	})
+	return argv.area
+}
+
+// setProcTitle overwrite the command line with title, and set the name of the main thread
+func setProcTitle(title string) {
+	if area := argvArea(); area != nil {
+		n := copy(area, title)
+		for i := n; i < len(area); i++ {
+			area[i] = 0
+		}
+	}
+	name := title
+	if len(name) > 15 {
+		name = name[:15]
+	}
+	_ = ioutil.WriteFile("/proc/self/comm", []byte(name), 0644)
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+// +build !linux
+
+package daemon
+
+// setProcTitle only implemented on Linux
+func setProcTitle(title string) {}
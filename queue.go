@@ -0,0 +1,181 @@
+package daemon
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// ErrQueueEmpty returned by Queue.Dequeue when there is no job waiting
+var ErrQueueEmpty = errors.New("queue is empty")
+
+const (
+	jobSuffix     = ".job"  // waiting jobs
+	workingSuffix = ".work" // jobs handed to the worker but not done yet
+)
+
+// Queue a small durable job queue, every job is a file in the queue directory,
+// jobs survive restarts and can be enqueued from the CLI or the control socket while the worker dequeues them
+type Queue struct {
+	dir string
+	seq uint64
+}
+
+// Job a dequeued job, call Done when it is processed, otherwise it is handed out again after a restart
+type Job struct {
+	Payload  []byte
+	filename string
+}
+
+// OpenQueue open the queue stored in dir. the jobs dequeued but never done stay in flight until Recover
+func OpenQueue(dir string) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Queue{dir: dir}, nil
+}
+
+// Recover requeue the jobs that were dequeued but never done. only the consumer calls it when it starts,
+// a producer would hand out the jobs in flight of the running consumer a second time
+func (queue *Queue) Recover() error {
+	working, err := filepath.Glob(filepath.Join(queue.dir, "*"+workingSuffix))
+	if err != nil {
+		return err
+	}
+	for _, filename := range working {
+		if err = os.Rename(filename, strings.TrimSuffix(filename, workingSuffix)+jobSuffix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Enqueue append a job to the queue
+func (queue *Queue) Enqueue(payload []byte) error {
+	seq := atomic.AddUint64(&queue.seq, 1)
+	name := fmt.Sprintf("%020d-%d-%06d", time.Now().UnixNano(), os.Getpid(), seq)
+	tmp := filepath.Join(queue.dir, name+".tmp")
+	if err := ioutil.WriteFile(tmp, payload, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(queue.dir, name+jobSuffix))
+}
+
+// Dequeue take the oldest job, ErrQueueEmpty when there is none
+func (queue *Queue) Dequeue() (*Job, error) {
+	jobs, err := queue.jobs()
+	if err != nil {
+		return nil, err
+	}
+	for _, filename := range jobs {
+		working := strings.TrimSuffix(filename, jobSuffix) + workingSuffix
+		// the rename claims the job, if it fails somebody else took it
+		if err = os.Rename(filename, working); err != nil {
+			continue
+		}
+		payload, err := ioutil.ReadFile(working)
+		if err != nil {
+			return nil, err
+		}
+		return &Job{Payload: payload, filename: working}, nil
+	}
+	return nil, ErrQueueEmpty
+}
+
+// Len number of waiting jobs
+func (queue *Queue) Len() (int, error) {
+	jobs, err := queue.jobs()
+	return len(jobs), err
+}
+
+// jobs waiting job files, oldest first
+func (queue *Queue) jobs() ([]string, error) {
+	jobs, err := filepath.Glob(filepath.Join(queue.dir, "*"+jobSuffix))
+	sort.Strings(jobs)
+	return jobs, err
+}
+
+// Done remove the processed job from the queue
+func (job *Job) Done() error {
+	return os.Remove(job.filename)
+}
+
+// EnableQueue give the worker a durable job queue next to its pid file and generate the enqueue command,
+// must be called before the process is added to the command tree
+func (process *Process) EnableQueue() *Process {
+	return process.configure("EnableQueue", func() {
+		process.queueEnabled = true
+	})
+}
+
+// Queue the job queue of the worker, see EnableQueue. in the child, the jobs its previous run left in flight are requeued
+func (process *Process) Queue() (*Queue, error) {
+	if process.queue == nil {
+		queue, err := OpenQueue(filepath.Join(filepath.Dir(process.pid.SaveFilename()), process.pid.ServicesName+".queue"))
+		if err == nil && process.IsChild() {
+			err = queue.Recover()
+		}
+		if err != nil {
+			return nil, err
+		}
+		process.queue = queue
+	}
+	return process.queue, nil
+}
+
+// enqueueControl add the job of an enqueue control request, its payload encoded with base64
+func (process *Process) enqueueControl(args []string) error {
+	if len(args) > 1 {
+		return errors.New("enqueue takes one argument, the payload encoded with base64")
+	}
+	var payload []byte
+	if len(args) == 1 {
+		var err error
+		if payload, err = base64.StdEncoding.DecodeString(args[0]); err != nil {
+			return err
+		}
+	}
+	queue, err := process.Queue()
+	if err != nil {
+		return err
+	}
+	return queue.Enqueue(payload)
+}
+
+func enqueue(worker *Process) *cobra.Command {
+	return &cobra.Command{
+		Use:   "enqueue [payload...]",
+		Short: fmt.Sprintf("add a job to the queue of %s, the payload is read from stdin without arguments", worker.worker.Name()),
+		RunE: runE(func(cmd *cobra.Command, args []string) error {
+			payload := []byte(strings.Join(args, " "))
+			if len(args) == 0 {
+				var err error
+				if payload, err = ioutil.ReadAll(os.Stdin); err != nil {
+					return failed(worker.result(EnqueueCommand, ""), err, 1)
+				}
+			}
+
+			// a running worker with a control socket takes the job itself, otherwise it is written to the queue directory
+			_, err := worker.request(ControlEnqueue+" "+base64.StdEncoding.EncodeToString(payload), false, waitGrace)
+			if err == errNoControl {
+				var queue *Queue
+				if queue, err = worker.Queue(); err == nil {
+					err = queue.Enqueue(payload)
+				}
+			}
+			if err != nil {
+				return failed(worker.result(EnqueueCommand, ""), err, 1)
+			}
+			return nil
+		}),
+	}
+}
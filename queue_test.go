@@ -0,0 +1,105 @@
+package daemon
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestQueue(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []string // "enqueue x", "dequeue x" (x the payload expected), "empty" (Dequeue fails), "done", "reopen", "recover"
+		left  int      // waiting jobs at the end
+	}{
+		{"empty", []string{"empty"}, 0},
+		{"fifo", []string{"enqueue a", "enqueue b", "dequeue a", "dequeue b", "empty"}, 0},
+		{"len", []string{"enqueue a", "enqueue b", "enqueue c", "dequeue a"}, 2},
+		{"done", []string{"enqueue a", "dequeue a", "done", "reopen", "empty"}, 0},
+		{"requeued when not done", []string{"enqueue a", "enqueue b", "dequeue a", "reopen", "recover", "dequeue a", "dequeue b"}, 0},
+		{"in flight when reopened", []string{"enqueue a", "enqueue b", "dequeue a", "reopen", "enqueue c", "done", "dequeue b", "dequeue c", "empty"}, 0},
+		{"empty payload", []string{"enqueue ", "dequeue ", "empty"}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "queue")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			queue, err := OpenQueue(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var job *Job
+			for _, step := range test.steps {
+				verb, payload := step, ""
+				if i := strings.Index(step, " "); i >= 0 {
+					verb, payload = step[:i], step[i+1:]
+				}
+				switch verb {
+				case "enqueue":
+					err = queue.Enqueue([]byte(payload))
+				case "dequeue":
+					if job, err = queue.Dequeue(); err == nil && string(job.Payload) != payload {
+						t.Fatalf("%s: got %q", step, job.Payload)
+					}
+				case "empty":
+					if _, err = queue.Dequeue(); err == ErrQueueEmpty {
+						err = nil
+					} else if err == nil {
+						t.Fatalf("%s: got a job", step)
+					}
+				case "done":
+					err = job.Done()
+				case "reopen":
+					queue, err = OpenQueue(dir)
+				case "recover":
+					err = queue.Recover()
+				}
+				if err != nil {
+					t.Fatalf("%s: %v", step, err)
+				}
+			}
+			if left, err := queue.Len(); err != nil || left != test.left {
+				t.Errorf("Len() = %d, %v, want %d", left, err, test.left)
+			}
+		})
+	}
+}
+
+func TestControlEnqueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	process := NewProcess(testWorker{dir: dir, name: "queue"}).EnableQueue()
+	payloads := []string{"a b\nc", ""}
+	for _, payload := range payloads {
+		if _, err = process.Control(ControlEnqueue, strings.Fields(base64.StdEncoding.EncodeToString([]byte(payload)))...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = process.Control(ControlEnqueue, "a", "b"); err == nil {
+		t.Error("enqueue with two arguments succeeded")
+	}
+
+	queue, err := process.Queue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, payload := range payloads {
+		job, err := queue.Dequeue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(job.Payload) != payload {
+			t.Errorf("dequeued %q, want %q", job.Payload, payload)
+		}
+	}
+}
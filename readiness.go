@@ -0,0 +1,111 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultReadyWait how long start --wait-ready waits for the worker to be ready, unless --wait is given
+	DefaultReadyWait = time.Minute
+	// readyInterval how often Ready and the probe of SetReadyProbe are polled
+	readyInterval = 200 * time.Millisecond
+	// probeTimeout a probe that takes longer failed
+	probeTimeout = 2 * time.Second
+)
+
+// Readier If the worker implements this interface, it is ready once Ready returns true, which is polled after Start
+// instead of the worker being ready at once. start --wait-ready waits for it
+type Readier interface {
+	Ready() bool
+}
+
+// ReadyProbe how start --wait-ready checks from outside that the worker serves
+type ReadyProbe struct {
+	TCP  string // an address that accepts connections, such as "127.0.0.1:9047"
+	HTTP string // a URL that answers with a 2xx status, such as "http://127.0.0.1:9047/healthz"
+}
+
+// SetReadyProbe let start --wait-ready poll probe once the child started, until it succeeds
+func (process *Process) SetReadyProbe(probe ReadyProbe) *Process {
+	return process.configure("SetReadyProbe", func() {
+		process.readyProbe = &probe
+	})
+}
+
+// waitsReady in the child, whether the start command was run with --wait-ready
+func (process *Process) waitsReady() bool {
+	return process.flag("wait-ready") == "true"
+}
+
+// awaitReady in the child of a worker that does not call SetReady, mark it ready: once Ready returns true for a Readier,
+// at once otherwise. the start command is told it started after startupGrace, or once a Readier is ready with --wait-ready
+func (process *Process) awaitReady() {
+	readier, polls := process.impl.(Readier)
+	waits := polls && process.waitsReady()
+	if !process.oneShot && !waits {
+		time.AfterFunc(startupGrace, func() { process.reportStartup(nil) })
+	}
+	if !polls {
+		_ = process.ready()
+		return
+	}
+
+	go func() {
+		for !readier.Ready() {
+			time.Sleep(readyInterval)
+		}
+		if process.ready() && waits && !process.oneShot {
+			process.reportStartup(nil)
+		}
+	}()
+}
+
+// waitReady in the start command with --wait-ready, poll the probe of SetReadyProbe until it succeeds or deadline passes
+func (process *Process) waitReady(deadline time.Time) error {
+	if process.readyProbe == nil {
+		return nil
+	}
+	for {
+		err := process.readyProbe.check()
+		switch {
+		case err == nil:
+			return nil
+		case process.exited():
+			return fmt.Errorf("%s exited before it was ready, see its error output", process.worker.Name())
+		case time.Now().After(deadline):
+			return fmt.Errorf("%s is not ready: %v", process.worker.Name(), err)
+		}
+		time.Sleep(readyInterval)
+	}
+}
+
+// exited whether the process of the pid file is gone
+func (process *Process) exited() bool {
+	pid, err := process.pid.Read()
+	return err == nil && !alive(pid)
+}
+
+// check probe once
+func (probe ReadyProbe) check() error {
+	if probe.HTTP != "" {
+		response, err := (&http.Client{Timeout: probeTimeout}).Get(probe.HTTP)
+		if err != nil {
+			return err
+		}
+		_ = response.Body.Close()
+		if response.StatusCode < 200 || response.StatusCode > 299 {
+			return fmt.Errorf("%s: %s", probe.HTTP, response.Status)
+		}
+	}
+	if probe.TCP != "" {
+		conn, err := net.DialTimeout("tcp", probe.TCP, probeTimeout)
+		if err != nil {
+			return err
+		}
+		_ = conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package daemon
+
+import "time"
+
+// reapInterval how often the subreaper looks for orphans, besides SIGCHLD
+const reapInterval = time.Second
+
+// SetSubreaper make the child the subreaper of the processes the worker starts (Linux only): the ones whose parent exits
+// are adopted by it instead of init, and reaped once they exit. with WithSupervision the supervisor adopts the processes
+// left by a crashed worker. a process the worker started itself has to be waited for as soon as it exits, as exec.Cmd.Run does,
+// it is reaped otherwise
+func (process *Process) SetSubreaper(enabled bool) *Process {
+	return process.configure("SetSubreaper", func() {
+		process.subreaper = enabled
+	})
+}
@@ -0,0 +1,73 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const prSetChildSubreaper = 36
+
+// becomeSubreaper in the child, adopt the orphans of the processes below it and reap them.
+// the init process adopts every orphan without asking
+func (process *Process) becomeSubreaper() error {
+	if !process.subreaper && !process.asInit {
+		return nil
+	}
+	if os.Getpid() == 1 {
+		go process.reapOrphans()
+		return nil
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 1, 0); errno != 0 {
+		return fmt.Errorf("subreaper: %v", errno)
+	}
+	go process.reapOrphans()
+	return nil
+}
+
+// reapOrphans reap the adopted processes that exited, on SIGCHLD and every reapInterval, until the process exits.
+// an exited process is reaped when it was seen with another parent before, or has been waiting for reapInterval:
+// a child started by this process is waited for by its exec.Cmd as soon as it exits
+func (process *Process) reapOrphans() {
+	exited := make(chan os.Signal, signalBuffer)
+	signal.Notify(exited, syscall.SIGCHLD)
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+
+	self := os.Getpid()
+	parents := make(map[int]int)       // the parents at the previous look
+	zombies := make(map[int]time.Time) // when the exited children were first seen
+	for {
+		table, now := processes(), time.Now()
+		waiting := make(map[int]time.Time)
+		for pid, stat := range table {
+			if stat.parent != self || stat.state != 'Z' {
+				continue
+			}
+			since, ok := zombies[pid]
+			if !ok {
+				since = now
+			}
+			if parent, known := parents[pid]; (!known || parent == self) && now.Sub(since) < reapInterval {
+				waiting[pid] = since
+				continue
+			}
+			var status syscall.WaitStatus
+			if reaped, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); err == nil && reaped == pid {
+				process.info("orphan reaped", "pid", pid, "code", status.ExitStatus())
+			}
+		}
+		zombies = waiting
+		parents = make(map[int]int, len(table))
+		for pid, stat := range table {
+			parents[pid] = stat.parent
+		}
+
+		select {
+		case <-exited:
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package daemon
+
+// becomeSubreaper subreapers only exist on Linux
+func (process *Process) becomeSubreaper() error {
+	if !process.subreaper {
+		return nil
+	}
+	return ErrUnsupported
+}
@@ -0,0 +1,62 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+// Reloader If the worker implements this interface, SIGHUP reloads it without a full restart
+// and the reload command is generated to send it
+type Reloader interface {
+	Reload() error
+}
+
+// register the default hangup method: reopen the outputs and reload the worker
+func (process *Process) registerDefaultHangupHandle() {
+	process.onDefault(syscall.SIGHUP, func() {
+		process.reopenOutputs()
+		if reloader, ok := process.impl.(Reloader); ok {
+			process.notify("RELOADING=1")
+			if err := reloader.Reload(); err != nil {
+				process.error("reload failed", "err", err)
+			}
+			process.notify("READY=1")
+		}
+	})
+}
+
+// keepHangup in the child, drop the default SIGHUP handler when there is nothing to reload or reopen,
+// so that SIGHUP keeps its default behaviour unless a handler of On takes it
+func (process *Process) keepHangup() {
+	if _, ok := process.impl.(Reloader); ok || process.logPaths != [2]string{} {
+		return
+	}
+	for _, output := range process.outputs {
+		if _, ok := output.(reopener); ok {
+			return
+		}
+	}
+	process.signals.removeDefaults(syscall.SIGHUP)
+}
+
+func reload(worker *Process) *cobra.Command {
+	return &cobra.Command{
+		Use:   "reload",
+		Short: fmt.Sprintf("reload %s without restarting it", worker.worker.Name()),
+		RunE: runE(func(cmd *cobra.Command, args []string) error {
+			if controlled, err := worker.tryControl(cmd, ControlReload, 0); controlled || err != nil {
+				return err
+			}
+			if _, err := worker.pid.signal(worker.pid.SaveFilename(), syscall.SIGHUP); err != nil {
+				if os.IsNotExist(err) {
+					err = fmt.Errorf("%s is %w", worker.worker.Name(), ErrNotRunning)
+				}
+				return failed(worker.result(ReloadCommand, ""), err, 1)
+			}
+			return nil
+		}),
+	}
+}
@@ -0,0 +1,153 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// states of a worker reported by the commands
+const (
+	StateRunning    = "running"
+	StateNotRunning = "not-running"
+	StateDead       = "dead"
+	StateStarted    = "started"
+	StateStopped    = "stopped"
+	StateRestarted  = "restarted"
+	StateUpgraded   = "upgraded"
+	StateUpdated    = "updated"
+	StateFailed     = "failed"
+	StateCompleted  = "completed"
+	StateKilled     = "killed"
+
+	StateInstalled    = "installed"
+	StateNotInstalled = "not-installed"
+	StateUninstalled  = "uninstalled"
+)
+
+// Result the outcome of a command for one worker, printed as one JSON line per worker with --output=json
+type Result struct {
+	Worker  string  `json:"worker"`
+	Command string  `json:"command"`
+	State   string  `json:"state"`
+	Pid     int     `json:"pid,omitempty"`
+	Uptime  float64 `json:"uptime,omitempty"` // seconds
+	Message string  `json:"message,omitempty"`
+	Error   string  `json:"error,omitempty"`
+
+	ExitCode int `json:"exit_code,omitempty"` // of a one-shot worker
+
+	Version       string `json:"version,omitempty"` // from the status file of the child
+	Restarts      int    `json:"restarts,omitempty"`
+	RestartReason string `json:"restart_reason,omitempty"`
+
+	Plan []string `json:"plan,omitempty"` // what the command would do, with --dry-run
+}
+
+func init() {
+	command.command.PersistentFlags().String("output", "text", "output format of the commands: text or json")
+}
+
+// jsonOutput whether cmd runs with --output=json
+func jsonOutput(cmd *cobra.Command) bool {
+	format, _ := cmd.Flags().GetString("output")
+	return format == "json"
+}
+
+// report print result with --output=json, the text formatted with args otherwise
+func report(cmd *cobra.Command, result Result, text string, args ...interface{}) {
+	if jsonOutput(cmd) {
+		_ = json.NewEncoder(os.Stdout).Encode(result)
+		return
+	}
+	fmt.Printf(text, args...)
+}
+
+// fail report err and exit with code, on stderr unless the output is JSON
+func fail(cmd *cobra.Command, result Result, err error, code int) {
+	if jsonOutput(cmd) {
+		if result.State == "" {
+			result.State = StateFailed
+		}
+		result.Error = err.Error()
+		_ = json.NewEncoder(os.Stdout).Encode(result)
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(code)
+}
+
+// CommandError a failed command, returned by its RunE. Run reports it like fail and exits with Code
+type CommandError struct {
+	Result Result
+	Err    error // nil exits with Code without a message
+	Code   int
+}
+
+func (err *CommandError) Error() string {
+	if err.Err == nil {
+		return fmt.Sprintf("exit status %d", err.Code)
+	}
+	return err.Err.Error()
+}
+
+func (err *CommandError) Unwrap() error {
+	return err.Err
+}
+
+// failed the error of a RunE that failed with err, reported as result and exiting with code
+func failed(result Result, err error, code int) error {
+	return &CommandError{Result: result, Err: err, Code: code}
+}
+
+// exitWith the error of a RunE that already reported, the command exits with code
+func exitWith(code int) error {
+	return &CommandError{Code: code}
+}
+
+// runE fn as the RunE of a command: a CommandError it returns is reported by Run instead of cobra, without the usage.
+// it fails without running fn when the config file or the environment could not be applied
+func runE(fn func(cmd *cobra.Command, args []string) error) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		err := command.initErr
+		if err == nil {
+			err = fn(cmd, args)
+		}
+		if err != nil {
+			var failure *CommandError
+			cmd.SilenceErrors, cmd.SilenceUsage = errors.As(err, &failure), true
+		}
+		return err
+	}
+}
+
+// exit report failure, returned by the command cmd, and exit with its code. without Err it exits silently
+func exit(cmd *cobra.Command, failure *CommandError) {
+	if failure.Err == nil {
+		os.Exit(failure.Code)
+	}
+	fail(cmd, failure.Result, failure.Err, failure.Code)
+}
+
+// result a result of verb for worker
+func (process *Process) result(verb, state string) Result {
+	return Result{Worker: process.pid.ServicesName, Command: verb, State: state}
+}
+
+// stateResult the result of verb describing state
+func stateResult(verb string, state State) Result {
+	result := Result{Worker: state.Name, Command: verb, State: StateNotRunning, Pid: state.Pid}
+	switch {
+	case state.Running:
+		result.State, result.Uptime = StateRunning, state.Uptime().Seconds()
+	case state.Pid != 0:
+		result.State = StateDead
+	}
+	if status := state.Status; status != nil {
+		result.Version, result.Restarts, result.RestartReason = status.Version, status.Restarts, status.RestartReason
+	}
+	return result
+}
@@ -0,0 +1,15 @@
+package daemon
+
+import (
+	"context"
+	"net"
+)
+
+// ListenReusePort like Listen, but the socket is bound with SO_REUSEPORT, so that the instances of SetInstances all
+// listen on the same address and the kernel spreads the connections between them. not supported on Windows
+func ListenReusePort(network, address string) (net.Listener, error) {
+	return listen(network, address, func(network, address string) (net.Listener, error) {
+		config := net.ListenConfig{Control: reusePort}
+		return config.Listen(context.Background(), network, address)
+	})
+}
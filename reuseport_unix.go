@@ -0,0 +1,21 @@
+//go:build darwin || freebsd || linux
+// +build darwin freebsd linux
+
+package daemon
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// reusePort set SO_REUSEPORT on the socket of conn before it is bound
+func reusePort(network, address string, conn syscall.RawConn) error {
+	var err error
+	if controlErr := conn.Control(func(fd uintptr) {
+		err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	}); controlErr != nil {
+		return controlErr
+	}
+	return err
+}
@@ -0,0 +1,8 @@
+package daemon
+
+import "syscall"
+
+// reusePort SO_REUSEPORT does not exist on Windows
+func reusePort(network, address string, conn syscall.RawConn) error {
+	return ErrUnsupported
+}
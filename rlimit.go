@@ -0,0 +1,27 @@
+package daemon
+
+import "fmt"
+
+// rlimit a resource limit set by SetRlimit
+type rlimit struct {
+	resource   int
+	soft, hard uint64
+}
+
+// SetRlimit limit resource, such as syscall.RLIMIT_NOFILE or syscall.RLIMIT_AS, in the child before worker.Start.
+// the limits are set before SetCredentials switches user, so that a child started as root can raise the hard limit
+func (process *Process) SetRlimit(resource int, soft, hard uint64) *Process {
+	return process.configure("SetRlimit", func() {
+		process.rlimits = append(process.rlimits, rlimit{resource: resource, soft: soft, hard: hard})
+	})
+}
+
+// applyRlimits set the limits of SetRlimit in the child
+func (process *Process) applyRlimits() error {
+	for _, limit := range process.rlimits {
+		if err := setrlimit(limit.resource, limit.soft, limit.hard); err != nil {
+			return fmt.Errorf("set limit %d to %d/%d: %v", limit.resource, limit.soft, limit.hard, err)
+		}
+	}
+	return nil
+}
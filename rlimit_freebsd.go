@@ -0,0 +1,8 @@
+package daemon
+
+import "syscall"
+
+// setrlimit set the soft and hard limits of resource, the infinity of RLIM_INFINITY stays -1
+func setrlimit(resource int, soft, hard uint64) error {
+	return syscall.Setrlimit(resource, &syscall.Rlimit{Cur: int64(soft), Max: int64(hard)})
+}
@@ -0,0 +1,11 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package daemon
+
+import "syscall"
+
+// setrlimit set the soft and hard limits of resource
+func setrlimit(resource int, soft, hard uint64) error {
+	return syscall.Setrlimit(resource, &syscall.Rlimit{Cur: soft, Max: hard})
+}
@@ -0,0 +1,6 @@
+package daemon
+
+// setrlimit Windows has no resource limits
+func setrlimit(resource int, soft, hard uint64) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,123 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RotatingLog a log file that is rotated when it grows over a size, usable as output of the child with SetOutput.
+// Reopen (triggered by SIGHUP) reopens the file after an external logrotate moved it
+type RotatingLog struct {
+	mutex      sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+	file       *os.File
+	size       int64
+}
+
+// NewRotatingLog open the log file at path, rotated when it reaches maxSizeMB, keeping maxBackups rotated files
+// for at most maxAgeDays. zero values disable the corresponding limit
+func NewRotatingLog(path string, maxSizeMB, maxBackups, maxAgeDays int) (*RotatingLog, error) {
+	log := &RotatingLog{
+		path:       path,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxBackups: maxBackups,
+		maxAge:     time.Duration(maxAgeDays) * 24 * time.Hour,
+	}
+	return log, log.open()
+}
+
+// open open the log file in append mode, creating its directory
+func (log *RotatingLog) open() error {
+	if err := os.MkdirAll(filepath.Dir(log.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(log.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	log.file, log.size = file, info.Size()
+	return nil
+}
+
+// Write write p, rotating the file first when p would make it exceed the maximum size
+func (log *RotatingLog) Write(p []byte) (int, error) {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	if log.maxSize > 0 && log.size > 0 && log.size+int64(len(p)) > log.maxSize {
+		if err := log.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := log.file.Write(p)
+	log.size += int64(n)
+	return n, err
+}
+
+// rotate move the current file aside, open a new one and remove the backups over the limits
+func (log *RotatingLog) rotate() error {
+	_ = log.file.Close()
+	stamp := time.Now().Format("20060102-150405.000")
+	backup := fmt.Sprintf("%s.%s", log.path, stamp)
+	// rotated twice within a millisecond, the suffix keeps the order and the older backup
+	for i := 1; exists(backup); i++ {
+		backup = fmt.Sprintf("%s.%s-%04d", log.path, stamp, i)
+	}
+	if err := os.Rename(log.path, backup); err != nil {
+		return err
+	}
+	if err := log.open(); err != nil {
+		return err
+	}
+
+	backups, err := filepath.Glob(log.path + ".*")
+	if err != nil {
+		return err
+	}
+	// the timestamp suffix sorts oldest first
+	sort.Strings(backups)
+	for i, filename := range backups {
+		expired := false
+		if log.maxAge > 0 {
+			if info, err := os.Stat(filename); err == nil && time.Since(info.ModTime()) > log.maxAge {
+				expired = true
+			}
+		}
+		if expired || log.maxBackups > 0 && i < len(backups)-log.maxBackups {
+			_ = os.Remove(filename)
+		}
+	}
+	return nil
+}
+
+// Reopen close and reopen the file, so that writes go to a new file after an external rotation
+func (log *RotatingLog) Reopen() error {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	_ = log.file.Close()
+	return log.open()
+}
+
+// Close close the file
+func (log *RotatingLog) Close() error {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	return log.file.Close()
+}
+
+// Name the path of the log file
+func (log *RotatingLog) Name() string {
+	return log.path
+}
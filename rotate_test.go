@@ -0,0 +1,91 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingLog(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxSize    int64
+		maxBackups int
+		writes     []string
+		current    string // content of the log file at the end
+		backups    int
+	}{
+		{"under the limit", 10, 0, []string{"abc", "def"}, "abcdef", 0},
+		{"rotated", 10, 0, []string{"abcdef", "ghijkl"}, "ghijkl", 1},
+		{"exactly the limit", 6, 0, []string{"abc", "def"}, "abcdef", 0},
+		{"larger than the limit", 4, 0, []string{"abcdef", "ghijkl"}, "ghijkl", 1},
+		{"backups kept", 4, 0, []string{"a1234", "b1234", "c1234", "d1234"}, "d1234", 3},
+		{"backups removed", 4, 2, []string{"a1234", "b1234", "c1234", "d1234"}, "d1234", 2},
+		{"unlimited", 0, 0, []string{"abcdef", "ghijkl"}, "abcdefghijkl", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "rotate")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			log := &RotatingLog{path: filepath.Join(dir, "logs", "out.log"), maxSize: test.maxSize, maxBackups: test.maxBackups}
+			if err = log.open(); err != nil {
+				t.Fatal(err)
+			}
+			for _, write := range test.writes {
+				if _, err = log.Write([]byte(write)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_ = log.Close()
+
+			if current, _ := ioutil.ReadFile(log.Name()); string(current) != test.current {
+				t.Errorf("log file contains %q, want %q", current, test.current)
+			}
+			backups, _ := filepath.Glob(log.Name() + ".*")
+			if len(backups) != test.backups {
+				t.Fatalf("%d backups, want %d: %v", len(backups), test.backups, backups)
+			}
+			// the newest backups are kept
+			for i, backup := range backups {
+				content, _ := ioutil.ReadFile(backup)
+				if want := test.writes[len(test.writes)-1-len(backups)+i]; string(content) != want {
+					t.Errorf("backup %d contains %q, want %q", i, content, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRotatingLogReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	log, err := NewRotatingLog(filepath.Join(dir, "out.log"), 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	_, _ = log.Write([]byte("before\n"))
+	// what logrotate does
+	if err = os.Rename(log.Name(), log.Name()+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = log.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = log.Write([]byte("after\n"))
+
+	for filename, want := range map[string]string{log.Name(): "after\n", log.Name() + ".1": "before\n"} {
+		if content, _ := ioutil.ReadFile(filename); string(content) != want {
+			t.Errorf("%s contains %q, want %q", filename, content, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+// LandlockRule a file or directory the worker can access once the ruleset of SetLandlock is enforced
+type LandlockRule struct {
+	Path  string // a file, or a directory and everything below it
+	Write bool   // also create, write and remove files, only read and execute otherwise
+}
+
+// landlockDefaults the paths the binary may need to be executed again, missing ones are skipped.
+// the Go runtime opens /dev/null read-write in place of a closed standard file
+var landlockDefaults = []LandlockRule{
+	{Path: "/lib"}, {Path: "/lib64"}, {Path: "/usr/lib"}, {Path: "/usr/lib64"}, {Path: "/etc/ld.so.cache"},
+	{Path: os.DevNull, Write: true},
+}
+
+// landlockAccounts needed when the child looks up the account of SetCredentials
+var landlockAccounts = []LandlockRule{{Path: "/etc/passwd"}, {Path: "/etc/group"}, {Path: "/etc/nsswitch.conf"}}
+
+// SetChroot change the root directory of the worker to path before worker.Start, once the pid file, the pipes and the sockets
+// are open. the child has to start as root, SetCredentials switches to the user after. the working directory becomes
+// the root of path, or the directory of SetWorkDir inside it. a restart executes the binary from inside path
+func (process *Process) SetChroot(path string) *Process {
+	return process.configure("SetChroot", func() {
+		process.chroot = path
+	})
+}
+
+// SetLandlock restrict the files the child can access to rules with a Landlock ruleset, Linux 5.13 and later.
+// the pid directory, the binary and the system libraries are always allowed. the ruleset is enforced from the start of the child,
+// before the worker opens anything. the child fails to start where Landlock is not available
+func (process *Process) SetLandlock(rules ...LandlockRule) *Process {
+	return process.configure("SetLandlock", func() {
+		process.landlockRules = append(process.landlockRules, rules...)
+	})
+}
+
+// landlockEnv name of the environment variable that marks a process already running under the Landlock ruleset
+func (process *Process) landlockEnv() string {
+	return process.daemonTag + "_LANDLOCK"
+}
+
+// restrict in the child, enforce the Landlock ruleset and execute the binary again under it.
+// a ruleset only applies to the thread that enforces it, the new program has no other thread yet.
+// it is inherited by the processes started from there, which do not enforce it again
+func (process *Process) restrict() error {
+	if len(process.landlockRules) == 0 || os.Getenv(process.landlockEnv()) != "" {
+		return nil
+	}
+
+	rules := []LandlockRule{
+		{Path: executable()},
+		{Path: filepath.Dir(process.pid.SaveFilename()), Write: true},
+	}
+	defaults := landlockDefaults
+	if process.credentials != nil {
+		defaults = append(append([]LandlockRule{}, defaults...), landlockAccounts...)
+	}
+	for _, rule := range defaults {
+		if _, err := os.Stat(rule.Path); err == nil {
+			rules = append(rules, rule)
+		}
+	}
+	if mount, err := cgroupMount(); err == nil && process.cgroup != nil {
+		rules = append(rules, LandlockRule{Path: mount, Write: true})
+	}
+	rules = append(rules, process.landlockRules...)
+
+	// the thread is never given back, every other goroutine is gone once the binary is executed again
+	runtime.LockOSThread()
+	if err := landlock(rules); err != nil {
+		return fmt.Errorf("landlock: %v", err)
+	}
+	_ = os.Setenv(process.landlockEnv(), "true")
+	if process.startup != nil {
+		// the binary executed again reports the startup
+		_ = os.Setenv(process.startupEnv(), strconv.Itoa(int(process.startup.Fd())))
+	}
+	return reexec()
+}
+
+// enterChroot change the root directory to the one of SetChroot. the pid directory is kept open to remove the files
+// of the instance, which are no longer reachable by their path
+func (process *Process) enterChroot() error {
+	if process.chroot == "" {
+		return nil
+	}
+	if err := process.pid.openDir(); err != nil {
+		return err
+	}
+	if err := chroot(process.chroot); err != nil {
+		return fmt.Errorf("chroot %s: %v", process.chroot, err)
+	}
+	dir := process.daemonize.Chdir
+	if dir == "" {
+		dir = "/"
+	}
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+	process.info("root changed", "root", process.chroot)
+	return nil
+}
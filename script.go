@@ -0,0 +1,62 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// ScriptEvent a lifecycle event that can run an external script
+type ScriptEvent string
+
+const (
+	// OnStart the worker was started in the child
+	OnStart ScriptEvent = "on_start"
+	// OnStop the worker was stopped
+	OnStop ScriptEvent = "on_stop"
+	// OnCrash the worker panicked
+	OnCrash ScriptEvent = "on_crash"
+	// OnRestart the worker is restarting
+	OnRestart ScriptEvent = "on_restart"
+	// OnGiveUp the supervisor of WithSupervision no longer starts a worker that keeps exiting
+	OnGiveUp ScriptEvent = "on_give_up"
+)
+
+// SetScript run the executable at path when event happens, for operators who want shell-level integration without writing Go.
+// the script receives DAEMON_EVENT, DAEMON_NAME, DAEMON_PID and DAEMON_PID_FILE (and DAEMON_CRASH on a crash) in its environment
+func (process *Process) SetScript(event ScriptEvent, path string) *Process {
+	return process.configure("SetScript", func() {
+		if process.scripts == nil {
+			process.scripts = make(map[ScriptEvent]string)
+		}
+		process.scripts[event] = path
+	})
+}
+
+// runScript run the script of event, waiting at most the stop timeout, extra are added to the environment
+func (process *Process) runScript(event ScriptEvent, extra ...string) {
+	path, ok := process.scripts[event]
+	if !ok {
+		return
+	}
+
+	ctx := context.Background()
+	if process.stopTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, process.stopTimeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("DAEMON_EVENT=%s", event),
+		fmt.Sprintf("DAEMON_NAME=%s", process.worker.Name()),
+		fmt.Sprintf("DAEMON_PID=%d", os.Getpid()),
+		fmt.Sprintf("DAEMON_PID_FILE=%s", process.pid.SaveFilename()),
+	)
+	cmd.Env = append(cmd.Env, extra...)
+	cmd.Stdout, cmd.Stderr = process.pipeline[1], process.pipeline[2]
+	if err := cmd.Run(); err != nil {
+		process.error("script failed", "event", event, "script", path, "err", err)
+	}
+}
@@ -0,0 +1,162 @@
+package daemon
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// ErrVerification the downloaded binary does not match its checksum or signature
+var ErrVerification = errors.New("verification failed")
+
+// SelfUpdate where the self-update command downloads the new binary from, and how it is verified
+type SelfUpdate struct {
+	// URL of the new binary, {os} and {arch} are replaced by runtime.GOOS and runtime.GOARCH
+	URL string
+	// ChecksumURL of the hex sha256 of the binary, such as the output of sha256sum. empty uses URL + ".sha256"
+	ChecksumURL string
+	// PublicKey when set, URL + ".sig" must hold the ed25519 signature of the binary, raw or base64
+	PublicKey ed25519.PublicKey
+	// Client downloads the files, a client with a 5 minutes timeout if nil
+	Client *http.Client
+}
+
+// EnableSelfUpdate generate the self-update command: it downloads the binary described by update, verifies it,
+// replaces the executable with it and upgrades the running worker, see the upgrade command
+func (process *Process) EnableSelfUpdate(update SelfUpdate) *Process {
+	return process.configure("EnableSelfUpdate", func() {
+		process.selfUpdate = &update
+	})
+}
+
+// url expand the placeholders of a URL of update
+func (update *SelfUpdate) url(url string) string {
+	return strings.NewReplacer("{os}", runtime.GOOS, "{arch}", runtime.GOARCH).Replace(url)
+}
+
+// download the body of url
+func (update *SelfUpdate) download(url string) ([]byte, error) {
+	client := update.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	response, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download %s: %s", url, response.Status)
+	}
+	return ioutil.ReadAll(response.Body)
+}
+
+// fetch download the new binary and verify its checksum and signature
+func (update *SelfUpdate) fetch() ([]byte, error) {
+	url := update.url(update.URL)
+	binary, err := update.download(url)
+	if err != nil {
+		return nil, err
+	}
+
+	checksumURL := update.url(update.ChecksumURL)
+	if checksumURL == "" {
+		checksumURL = url + ".sha256"
+	}
+	checksum, err := update.download(checksumURL)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(binary)
+	if fields := strings.Fields(string(checksum)); len(fields) == 0 || !strings.EqualFold(fields[0], hex.EncodeToString(sum[:])) {
+		return nil, fmt.Errorf("%s: checksum %w", url, ErrVerification)
+	}
+
+	if update.PublicKey != nil {
+		signature, err := update.download(url + ".sig")
+		if err != nil {
+			return nil, err
+		}
+		if len(signature) != ed25519.SignatureSize {
+			if signature, err = base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature))); err != nil {
+				return nil, fmt.Errorf("%s: signature %w: %v", url, ErrVerification, err)
+			}
+		}
+		if !ed25519.Verify(update.PublicKey, binary, signature) {
+			return nil, fmt.Errorf("%s: signature %w", url, ErrVerification)
+		}
+	}
+	return binary, nil
+}
+
+// replaceExecutable write binary next to the executable and rename it over the executable, so that a concurrent start
+// runs either the old or the new binary
+func replaceExecutable(binary []byte) error {
+	path := executable()
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".update-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err = file.Write(binary); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), info.Mode())
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+func selfUpdate(worker *Process) *cobra.Command {
+	update := &cobra.Command{
+		Use:   "self-update",
+		Short: fmt.Sprintf("download the new binary of %s, replace this one and upgrade the running worker", worker.worker.Name()),
+		Args:  cobra.NoArgs,
+		RunE: runE(func(cmd *cobra.Command, args []string) error {
+			binary, err := worker.selfUpdate.fetch()
+			if err == nil {
+				err = replaceExecutable(binary)
+			}
+			if err != nil {
+				return failed(worker.result(SelfUpdateCommand, ""), err, 1)
+			}
+
+			if pid, err := worker.pid.Read(); err != nil || !alive(pid) {
+				report(cmd, worker.result(SelfUpdateCommand, StateUpdated), "%s updated\n", executable())
+				return nil
+			}
+			state, err := worker.triggerUpgrade(cmd)
+			if err != nil {
+				return failed(worker.result(SelfUpdateCommand, StateUpdated), fmt.Errorf("%s updated, but: %w", executable(), err), 1)
+			}
+			report(cmd, worker.result(SelfUpdateCommand, state), "%s updated, %s %s\n", executable(), worker.worker.Name(), state)
+			return nil
+		}),
+	}
+
+	addWaitFlag(update)
+	return update
+}
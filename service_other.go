@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package daemon
+
+import "github.com/spf13/cobra"
+
+// serviceStart the Service Control Manager only exists on Windows
+func serviceStart(worker *Process, cmd *cobra.Command) (bool, error) {
+	return false, nil
+}
+
+// serviceStop the Service Control Manager only exists on Windows
+func serviceStop(worker *Process) (bool, error) {
+	return false, nil
+}
+
+// serviceRestart the Service Control Manager only exists on Windows
+func serviceRestart(worker *Process, cmd *cobra.Command) (bool, error) {
+	return false, nil
+}
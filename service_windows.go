@@ -0,0 +1,129 @@
+package daemon
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// serviceHandler run the worker as a Windows service, Start and Stop map onto the service control requests
+type serviceHandler struct {
+	process *Process
+}
+
+// Execute the service main loop called by svc.Run
+func (handler *serviceHandler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	process := handler.process
+	status <- svc.Status{State: svc.StartPending}
+	if err := process.savePid(); err != nil {
+		process.error("save pid failed", "err", err)
+		return false, 1
+	}
+	go process.start()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for request := range requests {
+		switch request.Cmd {
+		case svc.Interrogate:
+			status <- request.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			status <- svc.Status{State: svc.StopPending}
+			if err := process.within("stop", process.worker.Stop); err != nil {
+				process.error("stop failed", "err", err)
+			}
+			process.removePid()
+			return false, 0
+		}
+	}
+	return false, 0
+}
+
+// serviceStart start the worker through the Service Control Manager, installing the service on first use.
+// when the binary is started by the SCM, it runs the service handler instead. returns false to fall back to Run,
+// in the foreground or when this user may not manage services
+func serviceStart(worker *Process, cmd *cobra.Command) (bool, error) {
+	if worker.IsChild() {
+		return false, nil
+	}
+	if isService, err := svc.IsWindowsService(); err == nil && isService {
+		if err = svc.Run(worker.worker.Name(), &serviceHandler{process: worker}); err != nil {
+			worker.error("service failed", "err", err)
+			return true, err
+		}
+		return true, nil
+	}
+	if foregroundFlag(cmd) {
+		return false, nil
+	}
+
+	manager, err := mgr.Connect()
+	if err != nil {
+		return fallback(err)
+	}
+	defer manager.Disconnect()
+
+	service, err := manager.OpenService(worker.worker.Name())
+	if err != nil {
+		// the service is registered with the arguments of this very start command
+		path := strings.Fields(strings.TrimPrefix(cmd.Parent().CommandPath(), cmd.Root().Name()))
+		path = append(path, worker.verbName(StartCommand))
+		service, err = manager.CreateService(worker.worker.Name(), executable(), mgr.Config{DisplayName: worker.worker.Name()}, path...)
+		if err != nil {
+			return fallback(err)
+		}
+	}
+	defer service.Close()
+
+	if err = service.Start(); err != nil {
+		return fallback(err)
+	}
+	return true, nil
+}
+
+// fallback the result of serviceStart for err: the detached child is started instead when access is denied
+func fallback(err error) (bool, error) {
+	if errors.Is(err, windows.ERROR_ACCESS_DENIED) {
+		return false, nil
+	}
+	return true, err
+}
+
+// serviceStop stop the service through the Service Control Manager and wait for it
+func serviceStop(worker *Process) (bool, error) {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return false, nil
+	}
+	defer manager.Disconnect()
+	service, err := manager.OpenService(worker.worker.Name())
+	if err != nil {
+		return false, nil
+	}
+	defer service.Close()
+
+	status, err := service.Control(svc.Stop)
+	if err != nil {
+		return true, err
+	}
+	deadline := time.Now().Add(worker.stopTimeout + 5*time.Second)
+	for status.State != svc.Stopped && time.Now().Before(deadline) {
+		time.Sleep(waitInterval)
+		if status, err = service.Query(); err != nil {
+			break
+		}
+	}
+	return true, nil
+}
+
+// serviceRestart stop and start the service through the Service Control Manager
+func serviceRestart(worker *Process, cmd *cobra.Command) (bool, error) {
+	if stopped, err := serviceStop(worker); !stopped || err != nil {
+		return stopped, err
+	}
+	return serviceStart(worker, cmd)
+}
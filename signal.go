@@ -0,0 +1,218 @@
+package daemon
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// signalBuffer how many signals may wait for the dispatcher
+const signalBuffer = 16
+
+// terminationSignals signals that stop the process, they preempt queued handlers and are processed exactly once
+var terminationSignals = map[os.Signal]bool{os.Interrupt: true, syscall.SIGTERM: true, SIGUSR1: true}
+
+// terminations the termination signals with stop as the stop signal in place of SIGUSR1
+func terminations(stop os.Signal) map[os.Signal]bool {
+	return map[os.Signal]bool{os.Interrupt: true, syscall.SIGTERM: true, stop: true}
+}
+
+// dispatcher subscribe to the signals that have a handler, and only to them, so that the other signals keep
+// their default behaviour and other packages receive theirs
+type dispatcher struct {
+	mutex        sync.Mutex
+	handlers     signalHandlers
+	notifiers    map[os.Signal]chan os.Signal // one channel per signal, so that a signal can be deregistered alone
+	received     chan os.Signal               // where the notifiers forward to, nil until dispatching
+	terminations map[os.Signal]bool           // the termination signals, terminationSignals when nil
+}
+
+// signalHandler one handler of a signal
+type signalHandler struct {
+	fn       func()
+	once     bool // removed after its first run
+	fallback bool // a default handler, it runs after the handlers of the user
+}
+
+// registered whether sig has a handler
+func (dispatcher *dispatcher) registered(sig os.Signal) bool {
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+	return len(dispatcher.handlers[sig]) > 0
+}
+
+// take the handlers to run for sig in order, the handlers that run once are removed
+func (dispatcher *dispatcher) take(sig os.Signal) []func() {
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	var fns, fallbacks []func()
+	kept := dispatcher.handlers[sig][:0]
+	for _, handler := range dispatcher.handlers[sig] {
+		if handler.fallback {
+			fallbacks = append(fallbacks, handler.fn)
+		} else {
+			fns = append(fns, handler.fn)
+		}
+		if !handler.once {
+			kept = append(kept, handler)
+		}
+	}
+	dispatcher.handlers[sig] = kept
+	if len(kept) == 0 {
+		dispatcher.unsubscribe(sig)
+	}
+	return append(fns, fallbacks...)
+}
+
+// removeDefaults deregister the default handlers of sig, the ones of the user are kept
+func (dispatcher *dispatcher) removeDefaults(sig os.Signal) {
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+	kept := dispatcher.handlers[sig][:0]
+	for _, handler := range dispatcher.handlers[sig] {
+		if !handler.fallback {
+			kept = append(kept, handler)
+		}
+	}
+	dispatcher.handlers[sig] = kept
+	if len(kept) == 0 {
+		delete(dispatcher.handlers, sig)
+		dispatcher.unsubscribe(sig)
+	}
+}
+
+// terminates whether sig stops the process
+func (dispatcher *dispatcher) terminates(sig os.Signal) bool {
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+	if dispatcher.terminations != nil {
+		return dispatcher.terminations[sig]
+	}
+	return terminationSignals[sig]
+}
+
+// add register handler for sig, after the ones already registered. replace removes them first
+func (dispatcher *dispatcher) add(sig os.Signal, handler signalHandler, replace bool) {
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+	if replace {
+		dispatcher.handlers[sig] = nil
+	}
+	dispatcher.handlers[sig] = append(dispatcher.handlers[sig], handler)
+	if dispatcher.received != nil {
+		dispatcher.notify(sig)
+	}
+}
+
+// remove deregister the handlers of sig and unsubscribe from it
+func (dispatcher *dispatcher) remove(sig os.Signal) {
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+	delete(dispatcher.handlers, sig)
+	dispatcher.unsubscribe(sig)
+}
+
+// unsubscribe stop receiving sig, the mutex is held
+func (dispatcher *dispatcher) unsubscribe(sig os.Signal) {
+	if notifier, ok := dispatcher.notifiers[sig]; ok {
+		signal.Stop(notifier)
+		close(notifier)
+		delete(dispatcher.notifiers, sig)
+	}
+}
+
+// notify subscribe to sig, the mutex is held
+func (dispatcher *dispatcher) notify(sig os.Signal) {
+	if _, ok := dispatcher.notifiers[sig]; ok {
+		return
+	}
+	if dispatcher.notifiers == nil {
+		dispatcher.notifiers = make(map[os.Signal]chan os.Signal)
+	}
+	notifier := make(chan os.Signal, signalBuffer)
+	signal.Notify(notifier, sig)
+	dispatcher.notifiers[sig] = notifier
+
+	received := dispatcher.received
+	go func() {
+		for got := range notifier {
+			received <- got
+		}
+	}()
+}
+
+// Listen listen the signals that have a handler and dispatch them to the handlers from a single goroutine
+func (handlers signalHandlers) Listen() {
+	(&dispatcher{handlers: handlers}).dispatch(new(int32), func(os.Signal) {})
+}
+
+// dispatch run the handlers of received signals one at a time. a termination signal skips the queued ones,
+// later termination signals are ignored. terminating is set as soon as one is received,
+// so that a handler in flight (a restart) can see it and back off. every handled signal is reported to received first
+func (dispatcher *dispatcher) dispatch(terminating *int32, received func(os.Signal)) {
+	var (
+		terminate = make(chan os.Signal, 1)
+		queue     = make(chan os.Signal, signalBuffer)
+	)
+	dispatcher.mutex.Lock()
+	dispatcher.received = make(chan os.Signal, signalBuffer)
+	for sig, handlers := range dispatcher.handlers {
+		if len(handlers) > 0 {
+			dispatcher.notify(sig)
+		}
+	}
+	sig := dispatcher.received
+	dispatcher.mutex.Unlock()
+
+	go func() {
+		for got := range sig {
+			if !dispatcher.registered(got) {
+				continue
+			}
+			if !dispatcher.terminates(got) {
+				// never block the receiver, a termination signal must always get through
+				select {
+				case queue <- got:
+				default:
+				}
+				continue
+			}
+			if atomic.CompareAndSwapInt32(terminating, 0, 1) {
+				terminate <- got
+			}
+		}
+	}()
+
+	handle := func(got os.Signal) {
+		fns := dispatcher.take(got)
+		// deregistered while it was queued
+		if len(fns) == 0 {
+			return
+		}
+		received(got)
+		for _, fn := range fns {
+			fn()
+		}
+	}
+	for {
+		// check the termination first, select picks randomly among ready channels
+		select {
+		case got := <-terminate:
+			handle(got)
+			continue
+		default:
+		}
+
+		select {
+		case got := <-terminate:
+			handle(got)
+		case got := <-queue:
+			if atomic.LoadInt32(terminating) == 0 {
+				handle(got)
+			}
+		}
+	}
+}
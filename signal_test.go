@@ -0,0 +1,47 @@
+//go:build !windows
+// +build !windows
+
+package daemon
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// BenchmarkSignalDispatch the time from sending a signal to the process until its handler runs
+func BenchmarkSignalDispatch(b *testing.B) {
+	handled := make(chan struct{})
+	dispatcher := &dispatcher{handlers: make(signalHandlers)}
+	dispatcher.add(syscall.SIGWINCH, signalHandler{fn: func() { handled <- struct{}{} }}, false)
+	go dispatcher.dispatch(new(int32), func(os.Signal) {})
+
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		b.Fatal(err)
+	}
+	// the dispatcher subscribes once it runs, wait for a signal to get through and drain the extra ones
+	for delivered := false; !delivered; {
+		_ = self.Signal(syscall.SIGWINCH)
+		select {
+		case <-handled:
+			delivered = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	for drained := false; !drained; {
+		select {
+		case <-handled:
+		case <-time.After(50 * time.Millisecond):
+			drained = true
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := self.Signal(syscall.SIGWINCH); err != nil {
+			b.Fatal(err)
+		}
+		<-handled
+	}
+}
@@ -0,0 +1,158 @@
+package daemon
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// DefaultStartWait how long the start command waits for the child to report it started
+	DefaultStartWait = 10 * time.Second
+	// startupGrace how long a worker that is not a ReadyNotifier has to fail before the start command is told it started
+	startupGrace = time.Second
+)
+
+// startupEnv name of the environment variable holding the descriptor the child reports its startup on
+func (process *Process) startupEnv() string {
+	return process.daemonTag + "_STARTUP"
+}
+
+// startWait the value of --wait of the start command, negative when it should not wait.
+// a one-shot worker is waited for until it completes by default, a worker to be ready for DefaultReadyWait
+func startWait(cmd *cobra.Command, oneShot, waitReady bool) time.Duration {
+	wait, _ := cmd.Flags().GetDuration("wait")
+	switch {
+	case wait != 0:
+		return wait
+	case oneShot:
+		return math.MaxInt64
+	case waitReady:
+		return DefaultReadyWait
+	}
+	return DefaultStartWait
+}
+
+// awaitStartup in the parent, let the child spawned by cmd report its startup on a pipe read by waitStartup
+func (process *Process) awaitStartup(cmd *exec.Cmd) error {
+	// a windows process does not inherit extra files
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	process.startup = reader
+	process.passStartup(cmd, writer)
+	return nil
+}
+
+// passStartup hand the startup pipe file to the process started by cmd
+func (process *Process) passStartup(cmd *exec.Cmd, file *os.File) {
+	cmd.ExtraFiles = append(cmd.ExtraFiles, file)
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", process.startupEnv(), 2+len(cmd.ExtraFiles)))
+}
+
+// waitStartup in the parent, wait up to timeout for the child to report it started, or a one-shot one that it completed.
+// the error reported by the child is returned as is, a non-zero exit code as an *ExitError
+func (process *Process) waitStartup(timeout time.Duration) error {
+	reader := process.startup
+	if reader == nil {
+		return nil
+	}
+	process.startup = nil
+	defer reader.Close()
+
+	line := make(chan string, 1)
+	go func() {
+		message, _ := bufio.NewReader(reader).ReadString('\n')
+		line <- strings.TrimSpace(message)
+	}()
+
+	select {
+	case message := <-line:
+		switch {
+		case message == "ok":
+			// after a double fork or with a supervisor, the process that runs is not the one spawned
+			if pid, err := process.pid.Read(); err == nil {
+				process.spawned = pid
+			}
+			return nil
+		case strings.HasPrefix(message, "error "):
+			return reportedError(strings.TrimPrefix(message, "error "))
+		case strings.HasPrefix(message, "exit "):
+			code, _ := strconv.Atoi(strings.TrimPrefix(message, "exit "))
+			if code != 0 {
+				return &ExitError{Name: process.worker.Name(), Code: code}
+			}
+			return nil
+		}
+		return fmt.Errorf("%s exited during startup, see its error output", process.worker.Name())
+	case <-time.After(timeout):
+		return fmt.Errorf("%s did not report it started, %w after %s", process.worker.Name(), ErrTimeout, timeout)
+	}
+}
+
+// openStartup in the child, take the startup pipe of the parent. it is not passed on to the children of restarts
+func (process *Process) openStartup() {
+	fd, err := strconv.Atoi(os.Getenv(process.startupEnv()))
+	if err != nil {
+		return
+	}
+	_ = os.Unsetenv(process.startupEnv())
+	process.startup = os.NewFile(uintptr(fd), "startup")
+}
+
+// handOffStartup pass the startup pipe to the process started by cmd, which reports in place of this one
+func (process *Process) handOffStartup(cmd *exec.Cmd) {
+	if process.startup != nil {
+		process.passStartup(cmd, process.startup)
+	}
+}
+
+// closeStartup close the startup pipe of this process once it was handed off
+func (process *Process) closeStartup() {
+	process.startupMutex.Lock()
+	defer process.startupMutex.Unlock()
+	if process.startup != nil {
+		_ = process.startup.Close()
+		process.startup = nil
+	}
+}
+
+// reportStartup in the child, tell the parent that startup succeeded (err nil) or failed, only the first report is sent
+func (process *Process) reportStartup(err error) {
+	process.startupMutex.Lock()
+	defer process.startupMutex.Unlock()
+	if process.startup == nil {
+		return
+	}
+	message := "ok\n"
+	if err != nil {
+		message = fmt.Sprintf("error %s\n", strings.Replace(err.Error(), "\n", " ", -1))
+	}
+	_, _ = io.WriteString(process.startup, message)
+	_ = process.startup.Close()
+	process.startup = nil
+}
+
+// reportedError the error the child reported as message, wrapping the error of the package it ends with
+func reportedError(message string) error {
+	for _, known := range reported {
+		if strings.HasSuffix(message, known.Error()) {
+			return fmt.Errorf("%s%w", strings.TrimSuffix(message, known.Error()), known)
+		}
+	}
+	return errors.New(message)
+}
@@ -0,0 +1,94 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stateFormat version of the state file layout
+const stateFormat = 1
+
+// StatefulWorker If the worker implements this interface, the snapshot is persisted on stop/restart
+// and handed back to Restore on the next start, so simple stateful daemons survive restarts without external storage.
+type StatefulWorker interface {
+	Snapshot() ([]byte, error)
+	Restore(data []byte) error
+}
+
+// StateVersioner If a StatefulWorker also implements this interface, snapshots written by another version are not restored
+type StateVersioner interface {
+	StateVersion() int
+}
+
+// stateFile the persisted snapshot
+type stateFile struct {
+	Format  int       `json:"format"`
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+	Data    []byte    `json:"data"`
+}
+
+// stateFilename path of the snapshot, next to the pid file
+func (process *Process) stateFilename() string {
+	return filepath.Join(filepath.Dir(process.pid.SaveFilename()), process.pid.ServicesName+".state")
+}
+
+// stateVersion the version of the worker state, 0 when the worker does not declare one
+func (process *Process) stateVersion() int {
+	if versioner, ok := process.impl.(StateVersioner); ok {
+		return versioner.StateVersion()
+	}
+	return 0
+}
+
+// saveState persist the snapshot of a StatefulWorker
+func (process *Process) saveState() error {
+	worker, ok := process.impl.(StatefulWorker)
+	if !ok {
+		return nil
+	}
+	data, err := worker.Snapshot()
+	if err != nil {
+		return fmt.Errorf("snapshot %s: %v", process.worker.Name(), err)
+	}
+
+	body, err := json.Marshal(stateFile{Format: stateFormat, Version: process.stateVersion(), Time: time.Now(), Data: data})
+	if err != nil {
+		return err
+	}
+	// write aside and rename, a crash in the middle must not leave half a snapshot
+	filename := process.stateFilename()
+	if err = ioutil.WriteFile(filename+".tmp", body, 0600); err != nil {
+		return err
+	}
+	return os.Rename(filename+".tmp", filename)
+}
+
+// restoreState hand the persisted snapshot back to a StatefulWorker
+func (process *Process) restoreState() error {
+	worker, ok := process.impl.(StatefulWorker)
+	if !ok {
+		return nil
+	}
+	body, err := ioutil.ReadFile(process.stateFilename())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var state stateFile
+	if err = json.Unmarshal(body, &state); err != nil {
+		return fmt.Errorf("decode %s: %v", process.stateFilename(), err)
+	}
+	if state.Format != stateFormat || state.Version != process.stateVersion() {
+		process.info("ignoring state of another version", "version", state.Version, "want", process.stateVersion())
+		return nil
+	}
+	return worker.Restore(state.Data)
+}
@@ -0,0 +1,103 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// PidRecord the pid of a running worker and when it was saved, a restarted worker saves a newer one
+type PidRecord struct {
+	Pid   int
+	Saved time.Time
+}
+
+// StateStore where the pids of the running workers are recorded, instead of the pid files, such as an in-memory
+// store for tests or etcd. the records are keyed by the path of the pid file, see Pid.SaveFilename, which names the
+// worker and its instance even when no file is written. the other files of the pid directory are still written
+type StateStore interface {
+	// Save record the pid of the worker called name
+	Save(name string, record PidRecord) error
+	// Load the pid recorded for name, an error wrapping os.ErrNotExist when there is none
+	Load(name string) (PidRecord, error)
+	// Remove forget the pid recorded for name
+	Remove(name string) error
+}
+
+// SetStateStore record the pid of the worker in store instead of the pid file. the start, stop, status and restart
+// commands resolve the running pid through it, so the store has to be shared by the processes of the commands.
+// a store does not lock like the pid file does, a worker already recorded and alive is refused at start
+func (process *Process) SetStateStore(store StateStore) *Process {
+	return process.configure("SetStateStore", func() {
+		process.pid.Store = store
+	})
+}
+
+// MemoryStore a StateStore that keeps the records in memory, for tests and for workers run in the foreground
+type MemoryStore struct {
+	mutex   sync.Mutex
+	records map[string]PidRecord
+}
+
+// NewMemoryStore an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]PidRecord)}
+}
+
+// Save record the pid of the worker called name
+func (store *MemoryStore) Save(name string, record PidRecord) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.records[name] = record
+	return nil
+}
+
+// Load the pid recorded for name
+func (store *MemoryStore) Load(name string) (PidRecord, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	record, ok := store.records[name]
+	if !ok {
+		return PidRecord{}, &os.PathError{Op: "load", Path: name, Err: os.ErrNotExist}
+	}
+	return record, nil
+}
+
+// Remove forget the pid recorded for name
+func (store *MemoryStore) Remove(name string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.records, name)
+	return nil
+}
+
+// record the pid recorded in the pid file filename, or for it in the store
+func (pid Pid) record(filename string) (PidRecord, error) {
+	if pid.Store != nil {
+		return pid.Store.Load(filename)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return PidRecord{}, err
+	}
+	number, err := readPid(filename)
+	return PidRecord{Pid: number, Saved: info.ModTime()}, err
+}
+
+// saveRecord record the pid in the store, refused while another living process of this binary is recorded
+func (pid *Pid) saveRecord() error {
+	filename := pid.SaveFilename()
+	if previous, err := pid.Store.Load(filename); err == nil && previous.Pid != pid.Pid && alive(previous.Pid) && pid.verify(previous.Pid) == nil {
+		return fmt.Errorf("%s (pid %d): %w", filename, previous.Pid, ErrAlreadyRunning)
+	}
+	return pid.Store.Save(filename, PidRecord{Pid: pid.Pid, Saved: time.Now()})
+}
+
+// forget remove the pid file filename, or its record in the store
+func (pid *Pid) forget(filename string) error {
+	if pid.Store != nil {
+		return pid.Store.Remove(filename)
+	}
+	return pid.remove(filename)
+}
@@ -0,0 +1,51 @@
+package daemon
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// signalHistorySize how many handled signals Stats remembers
+const signalHistorySize = 32
+
+// Stats the supervision info of the running worker, for embedders that display it themselves, such as an admin UI
+// served by the worker
+type Stats struct {
+	Started       time.Time
+	Uptime        time.Duration
+	Restarts      int            // restarts since the first start
+	RestartReason string         // why the worker was last restarted, empty after the first start
+	LastExit      int            // exit code of the previous worker under supervision, -1 if unknown
+	Signals       []SignalRecord // the last signals handled, oldest first
+}
+
+// SignalRecord a signal handled by the worker
+type SignalRecord struct {
+	Signal string
+	At     time.Time
+}
+
+// lastExitEnv name of the environment variable that passes the exit code of the previous supervised worker
+func (process *Process) lastExitEnv() string {
+	return process.daemonTag + "_LAST_EXIT"
+}
+
+// Stats the supervision info of the worker, meaningful in the child
+func (process *Process) Stats() Stats {
+	lastExit, err := strconv.Atoi(os.Getenv(process.lastExitEnv()))
+	if err != nil {
+		lastExit = -1
+	}
+	process.history.Lock()
+	signals := append([]SignalRecord(nil), process.history.records...)
+	process.history.Unlock()
+	return Stats{
+		Started:       process.started,
+		Uptime:        time.Since(process.started),
+		Restarts:      process.restarts(),
+		RestartReason: os.Getenv(process.restartReasonEnv()),
+		LastExit:      lastExit,
+		Signals:       signals,
+	}
+}
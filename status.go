@@ -0,0 +1,88 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// ExitDead LSB exit code of status when the pid file exists but the program is dead
+const ExitDead = 1
+
+// State the state of a worker as reported by the status command
+type State struct {
+	Name       string
+	Running    bool
+	Pid        int
+	PidFile    string
+	Started    time.Time
+	Executable string
+	Status     *StatusFile // the status file of the child, nil without one
+}
+
+// Uptime how long the worker has been running
+func (state State) Uptime() time.Duration {
+	if !state.Running {
+		return 0
+	}
+	return time.Since(state.Started).Round(time.Second)
+}
+
+// State read the pid file and check that the recorded process is alive
+func (process *Process) State() (State, error) {
+	state := State{Name: process.pid.ServicesName, PidFile: process.pid.SaveFilename()}
+	pid, err := process.pid.Read()
+	if err != nil {
+		return state, err
+	}
+	state.Pid = pid
+	if !alive(pid) || process.pid.verify(pid) != nil {
+		return state, nil
+	}
+
+	state.Running = true
+	// the pid file is written when the child starts
+	if saved, err := process.pid.saved(); err == nil {
+		state.Started = saved
+	}
+	if state.Executable, err = processExecutable(pid); err != nil {
+		state.Executable = executable()
+	}
+	state.Status = process.readStatus()
+	return state, nil
+}
+
+func status(worker *Process) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: fmt.Sprintf("show whether %s is running", worker.worker.Name()),
+		RunE: runE(func(cmd *cobra.Command, args []string) error {
+			// the control socket answers in text
+			if !jsonOutput(cmd) {
+				if controlled, err := worker.tryControl(cmd, ControlStatus, 0); controlled || err != nil {
+					return err
+				}
+			}
+			if filenames := worker.instanceFiles(); len(filenames) > 0 {
+				return worker.statusInstances(cmd, filenames)
+			}
+			state, err := worker.State()
+			result := stateResult(StatusCommand, state)
+			switch {
+			case err != nil && os.IsNotExist(err):
+				report(cmd, result, "%s is not running\n", state.Name)
+				return exitWith(ExitNotRunning)
+			case err != nil:
+				return failed(result, err, ExitDead)
+			case !state.Running:
+				report(cmd, result, "%s is dead but its pid file %s exists (pid %d)\n", state.Name, state.PidFile, state.Pid)
+				return exitWith(ExitDead)
+			}
+
+			report(cmd, result, "%s", runningText(state))
+			return nil
+		}),
+	}
+}
@@ -0,0 +1,128 @@
+package daemon
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StatusFile the runtime information the child keeps in <name>.status next to the pid file, read by the status
+// command even when the control socket is unavailable
+type StatusFile struct {
+	Pid           int       `json:"pid"`
+	Started       time.Time `json:"started"`
+	Executable    string    `json:"executable"`
+	BinaryHash    string    `json:"binary_hash"` // sha256 of the executable
+	Version       string    `json:"version,omitempty"`
+	Restarts      int       `json:"restarts"`
+	RestartReason string    `json:"restart_reason,omitempty"` // why the worker was last restarted
+	Ready         bool      `json:"ready"`
+	Healthy       bool      `json:"healthy"`
+	Updated       time.Time `json:"updated"`
+}
+
+var (
+	binaryHash     string
+	binaryHashOnce sync.Once
+)
+
+// statusFilename path of the status file, next to the pid file
+func (process *Process) statusFilename() string {
+	return process.pid.statusFilename()
+}
+
+// restartReasonEnv name of the environment variable that tells the new child why it was started
+func (process *Process) restartReasonEnv() string {
+	return process.daemonTag + "_RESTART_REASON"
+}
+
+// requestRestart restart the worker through the restart handler, reason is recorded in the status file of the new child
+func (process *Process) requestRestart(reason string) error {
+	process.reason.Store(reason)
+	return process.signalSelf(process.restartSignal)
+}
+
+// restartReason why the worker restarts, a restart signal without request came from the restart command
+func (process *Process) restartReason() string {
+	reason, _ := process.reason.Load().(string)
+	if reason == "" {
+		reason = "restart requested"
+	}
+	return reason
+}
+
+// passRestartReason tell the new child why it is started
+func (process *Process) passRestartReason() {
+	_ = os.Setenv(process.restartReasonEnv(), process.restartReason())
+}
+
+// writeStatus in the child, save the status file, a failure is only logged
+func (process *Process) writeStatus() {
+	binaryHashOnce.Do(func() {
+		if stamp, err := stampFile(executable(), nil); err == nil {
+			binaryHash = hex.EncodeToString(stamp.sum)
+		}
+	})
+	status := StatusFile{
+		Pid:           os.Getpid(),
+		Started:       process.started,
+		Executable:    executable(),
+		BinaryHash:    binaryHash,
+		Version:       Version().Version,
+		Restarts:      process.restarts(),
+		RestartReason: os.Getenv(process.restartReasonEnv()),
+		Ready:         atomic.LoadInt32(&process.isReady) == 1,
+		Healthy:       atomic.LoadInt32(&process.unhealthy) == 0,
+		Updated:       time.Now(),
+	}
+	body, err := json.Marshal(status)
+	if err == nil {
+		// write aside and rename, the status command must not read half a file
+		filename := process.statusFilename()
+		if err = ioutil.WriteFile(filename+".tmp", body, 0644); err == nil {
+			err = os.Rename(filename+".tmp", filename)
+		}
+	}
+	if err != nil {
+		process.error("write status file failed", "err", err)
+	}
+}
+
+// readStatus the status file of the running child, nil when there is none or its process is gone
+func (process *Process) readStatus() *StatusFile {
+	return readStatusFile(process.statusFilename())
+}
+
+// readStatusFile the status file filename of a living process, nil otherwise
+func readStatusFile(filename string) *StatusFile {
+	body, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+	status := new(StatusFile)
+	if err = json.Unmarshal(body, status); err != nil || !alive(status.Pid) {
+		return nil
+	}
+	return status
+}
+
+// runningText the status of a running worker as printed by the status command
+func runningText(state State) string {
+	text := fmt.Sprintf("%s is running\n  pid:        %d\n  uptime:     %s\n  executable: %s\n",
+		state.Name, state.Pid, state.Uptime(), state.Executable)
+	if status := state.Status; status != nil {
+		text += fmt.Sprintf("  version:    %s\n  binary:     sha256:%.12s\n  restarts:   %d\n", status.Version, status.BinaryHash, status.Restarts)
+		if status.RestartReason != "" {
+			text += fmt.Sprintf("  restarted:  %s\n", status.RestartReason)
+		}
+		if !status.Healthy {
+			text += "  health:     failing\n"
+		}
+	}
+	return text
+}
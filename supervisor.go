@@ -0,0 +1,205 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// RestartMode when a supervised worker is started again after it exited
+type RestartMode int
+
+const (
+	// RestartNever no supervision, the daemonized child runs the worker itself
+	RestartNever RestartMode = iota
+	// RestartOnFailure start the worker again when it exits with a non-zero code, a panic for example
+	RestartOnFailure
+	// RestartAlways start the worker again whenever it exits, unless it was stopped through the daemon
+	RestartAlways
+)
+
+// RestartPolicy how a supervised worker is started again, so that a crash-looping worker does not hammer the machine.
+// the delay before a start grows with the number of restarts within Window
+type RestartPolicy struct {
+	InitialDelay time.Duration // delay before the first restart
+	Multiplier   float64       // factor applied to the delay for every further restart within Window
+	MaxDelay     time.Duration // upper bound of the delay
+	MaxRestarts  int           // give up after that many restarts within Window, zero never gives up
+	Window       time.Duration // restarts older than this are forgotten, zero never forgets them
+}
+
+// DefaultRestartPolicy the policy of supervised workers unless SetRestartPolicy is used
+var DefaultRestartPolicy = RestartPolicy{
+	InitialDelay: time.Second,
+	Multiplier:   2,
+	MaxDelay:     time.Minute,
+	MaxRestarts:  10,
+	Window:       10 * time.Minute,
+}
+
+// delay the delay before the next start when restarts happened within the window, false when the worker should be given up
+func (policy RestartPolicy) delay(restarts int) (time.Duration, bool) {
+	if policy.MaxRestarts > 0 && restarts >= policy.MaxRestarts {
+		return 0, false
+	}
+	delay := float64(policy.InitialDelay)
+	for i := 0; i < restarts; i++ {
+		delay *= policy.Multiplier
+		if policy.MaxDelay > 0 && delay >= float64(policy.MaxDelay) {
+			return policy.MaxDelay, true
+		}
+	}
+	return time.Duration(delay), true
+}
+
+// within whether a restart at is still counted by now
+func (policy RestartPolicy) within(at, now time.Time) bool {
+	return policy.Window <= 0 || now.Sub(at) < policy.Window
+}
+
+// SetRestartPolicy configure the backoff of supervised restarts
+func (process *Process) SetRestartPolicy(policy RestartPolicy) *Process {
+	return process.configure("SetRestartPolicy", func() {
+		process.restartPolicy = policy
+	})
+}
+
+// WithSupervision let the daemonized child supervise the worker in a process of its own and start it again according to mode,
+// so workers come back after panics without an external process manager. the pid file records the supervisor
+func (process *Process) WithSupervision(mode RestartMode) *Process {
+	return process.configure("WithSupervision", func() {
+		process.supervision = mode
+	})
+}
+
+// supervisedEnv name of the environment variable that marks the worker process started by a supervisor
+func (process *Process) supervisedEnv() string {
+	return process.daemonTag + "_SUPERVISED"
+}
+
+// supervised whether this process is a worker started by a supervisor
+func (process *Process) supervised() bool {
+	return os.Getenv(process.supervisedEnv()) == "true"
+}
+
+// shouldRestart whether the worker has to be started again after exiting with code
+func (process *Process) shouldRestart(code int) bool {
+	switch process.supervision {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return code != 0
+	}
+	return false
+}
+
+// supervise run the worker in a child process and start it again when it exits, until a stop signal is received.
+// stop signals are forwarded to the worker, a restart signal stops the worker and starts a new one
+func (process *Process) supervise() error {
+	if err := process.pid.Save(); err != nil {
+		return err
+	}
+	sig := make(chan os.Signal, signalBuffer)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM, process.stopSignal, process.restartSignal)
+
+	var restarts []time.Time
+	reason, lastExit := os.Getenv(process.restartReasonEnv()), -1
+	for started := process.restarts(); ; started++ {
+		cmd := exec.Command(executable(), os.Args[1:]...)
+		cmd.Args = titled(process.procTitle("worker"), cmd.Args)
+		cmd.Env = append(os.Environ(), fmt.Sprintf("%s=true", process.supervisedEnv()), fmt.Sprintf("%s=%d", process.restartsEnv(), started))
+		if reason != "" {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", process.restartReasonEnv(), reason))
+		}
+		if lastExit >= 0 {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", process.lastExitEnv(), lastExit))
+		}
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		// the first worker reports the startup to the start command
+		process.handOffStartup(cmd)
+		exited := make(chan int, 1)
+		err := cmd.Start()
+		process.closeStartup()
+		if err != nil {
+			process.error("start supervised worker failed", "err", err)
+			exited <- -1
+		} else {
+			go func() {
+				_ = cmd.Wait()
+				exited <- cmd.ProcessState.ExitCode()
+			}()
+		}
+
+		select {
+		case code := <-exited:
+			if !process.shouldRestart(code) {
+				process.pid.Remove()
+				return nil
+			}
+
+			now := time.Now()
+			recent := restarts[:0]
+			for _, at := range restarts {
+				if process.restartPolicy.within(at, now) {
+					recent = append(recent, at)
+				}
+			}
+			restarts = recent
+			delay, ok := process.restartPolicy.delay(len(restarts))
+			if !ok {
+				process.error("worker keeps exiting, giving up", "code", code, "restarts", len(restarts), "window", process.restartPolicy.Window)
+				process.emit(OnGiveUp, "")
+				process.pid.Remove()
+				return nil
+			}
+			restarts = append(restarts, now)
+			reason, lastExit = fmt.Sprintf("exited with code %d", code), code
+			process.info("worker exited, starting it again", "code", code, "delay", delay)
+			process.publish(Event{Type: EventRestartScheduled, Code: code, Reason: reason})
+
+			select {
+			case <-time.After(delay):
+			case received := <-sig:
+				// a restart signal only skips the delay
+				if received != process.restartSignal {
+					process.pid.Remove()
+					os.Exit(0)
+				}
+			}
+		case received := <-sig:
+			lastExit = process.stopSupervised(cmd, exited)
+			if received != process.restartSignal {
+				process.pid.Remove()
+				os.Exit(0)
+			}
+			reason = "restart requested"
+			process.publish(Event{Type: EventRestartScheduled, Code: lastExit, Reason: reason})
+		}
+	}
+}
+
+// stopSupervised ask the supervised worker to stop, killing it when it does not exit within the stop timeout,
+// returns its exit code
+func (process *Process) stopSupervised(cmd *exec.Cmd, exited chan int) int {
+	if cmd.Process == nil {
+		return -1
+	}
+	_ = cmd.Process.Signal(process.stopSignal)
+	if process.stopTimeout <= 0 {
+		return <-exited
+	}
+	select {
+	case code := <-exited:
+		return code
+	case <-time.After(process.stopTimeout + time.Second):
+		process.error("supervised worker did not stop, killing it", "timeout", process.stopTimeout)
+		if process.killGroup {
+			_ = signalGroup(cmd.Process.Pid, syscall.SIGKILL)
+		}
+		_ = cmd.Process.Kill()
+		return <-exited
+	}
+}
@@ -0,0 +1,98 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRestartPolicyDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RestartPolicy
+		restarts int
+		delay    time.Duration
+		ok       bool
+	}{
+		{"first restart", DefaultRestartPolicy, 0, time.Second, true},
+		{"grows", DefaultRestartPolicy, 3, 8 * time.Second, true},
+		{"capped", DefaultRestartPolicy, 7, time.Minute, true},
+		{"given up", DefaultRestartPolicy, 10, 0, false},
+		{"never given up", RestartPolicy{InitialDelay: time.Second, Multiplier: 2, MaxDelay: time.Minute}, 100, time.Minute, true},
+		{"no cap", RestartPolicy{InitialDelay: time.Second, Multiplier: 3}, 4, 81 * time.Second, true},
+		{"constant", RestartPolicy{InitialDelay: time.Second, Multiplier: 1, MaxDelay: time.Minute}, 50, time.Second, true},
+		{"no delay", RestartPolicy{Multiplier: 2}, 5, 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delay, ok := test.policy.delay(test.restarts)
+			if ok != test.ok {
+				t.Fatalf("delay(%d) ok = %v, want %v", test.restarts, ok, test.ok)
+			}
+			if delay != test.delay {
+				t.Errorf("delay(%d) = %v, want %v", test.restarts, delay, test.delay)
+			}
+		})
+	}
+}
+
+func TestRestartPolicyWithin(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		window time.Duration
+		at     time.Time
+		within bool
+	}{
+		{"recent", time.Minute, now.Add(-time.Second), true},
+		{"forgotten", time.Minute, now.Add(-time.Hour), false},
+		{"unbounded", 0, now.Add(-24 * time.Hour), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if within := (RestartPolicy{Window: test.window}).within(test.at, now); within != test.within {
+				t.Errorf("within = %v, want %v", within, test.within)
+			}
+		})
+	}
+}
+
+func TestControlStopSupervised(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stop signal is not sent on windows")
+	}
+	dir, err := ioutil.TempDir("", "supervised")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the supervisor owns the pid file, a stop request received by the worker goes to it
+	supervisor := exec.Command("sleep", "30")
+	if err = supervisor.Start(); err != nil {
+		t.Skip(err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- supervisor.Wait() }()
+	defer supervisor.Process.Kill()
+
+	process := NewProcess(testWorker{dir: dir, name: "supervised"})
+	if err = ioutil.WriteFile(process.pid.SaveFilename(), []byte(strconv.Itoa(supervisor.Process.Pid)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Setenv(process.supervisedEnv(), "true")
+	defer os.Unsetenv(process.supervisedEnv())
+
+	if _, err = process.Control(ControlStop); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Error("the supervisor did not receive the stop signal")
+	}
+}
@@ -2,11 +2,19 @@
 
 package daemon
 
-import "syscall"
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
 
 const (
-	SIGUSR1 = syscall.SIGUSR1
-	SIGUSR2 = syscall.SIGUSR2
+	SIGUSR1  = syscall.SIGUSR1
+	SIGUSR2  = syscall.SIGUSR2
+	SIGWINCH = syscall.SIGWINCH
 
 	LOCK_EX = syscall.LOCK_EX
 	LOCK_NB = syscall.LOCK_NB
@@ -15,3 +23,94 @@ const (
 func Flock(fd int, how int) error {
 	return syscall.Flock(fd, how)
 }
+
+// probe check that pid can be signaled with signal 0,
+// syscall.ESRCH when the process does not exist, syscall.EPERM when it belongs to another user
+func probe(pid int) error {
+	return syscall.Kill(pid, 0)
+}
+
+// alive whether a process with pid exists, a process owned by another user counts as alive
+func alive(pid int) bool {
+	err := probe(pid)
+	return err == nil || err == syscall.EPERM
+}
+
+// groupAlive whether any process of the process group led by pid is still alive
+func groupAlive(pid int) bool {
+	err := syscall.Kill(-pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// signalGroup send sig to the process group led by pid
+func signalGroup(pid int, sig syscall.Signal) error {
+	return syscall.Kill(-pid, sig)
+}
+
+// leadGroup make this process the leader of a new process group, unless it already is, as a session leader
+func leadGroup() error {
+	if syscall.Getpgrp() == os.Getpid() {
+		return nil
+	}
+	return syscall.Setpgid(0, 0)
+}
+
+// reexec replace the running program with a new instance of the binary, keeping the pid
+func reexec() error {
+	return syscall.Exec(executable(), os.Args, os.Environ())
+}
+
+// setsid start cmd in a new session, without controlling terminal
+func setsid(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+}
+
+// closeInheritedFiles mark every file above stderr close-on-exec, files opened by Go already are,
+// this catches the ones inherited from the shell or the parent of the start command
+func closeInheritedFiles() {
+	dir, err := os.Open("/dev/fd")
+	if err != nil {
+		return
+	}
+	names, _ := dir.Readdirnames(-1)
+	_ = dir.Close()
+	for _, name := range names {
+		if fd, err := strconv.Atoi(name); err == nil && fd > 2 {
+			syscall.CloseOnExec(fd)
+		}
+	}
+}
+
+// umask set the file mode creation mask
+func umask(mask int) {
+	syscall.Umask(mask)
+}
+
+// setCredentials switch the process, every thread of it, to uid, gid and the supplementary groups
+func setCredentials(uid, gid int, groups []int) error {
+	if err := syscall.Setgroups(groups); err != nil {
+		return err
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return err
+	}
+	return syscall.Setuid(uid)
+}
+
+// chroot change the root directory of the process
+func chroot(path string) error {
+	return syscall.Chroot(path)
+}
+
+// unlinkat remove the file called name in the directory dir
+func unlinkat(dir *os.File, name string) error {
+	return unix.Unlinkat(int(dir.Fd()), name, 0)
+}
+
+// dup2 make newfd a copy of oldfd
+func dup2(oldfd, newfd int) error {
+	return unix.Dup2(oldfd, newfd)
+}
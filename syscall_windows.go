@@ -1,5 +1,11 @@
 package daemon
 
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
 // Integer Windows信号支持, 只能保证Windows能运行, 信号应该是无法发送的
 type Integer int
 
@@ -9,6 +15,8 @@ func (integer Integer) String() string {
 		return "USR1"
 	case SIGUSR2:
 		return "USR2"
+	case SIGWINCH:
+		return "WINCH"
 	default:
 		return "UNDEFINED"
 	}
@@ -19,13 +27,85 @@ func (integer Integer) Signal() {
 }
 
 const (
-	SIGUSR1 = Integer(0x1e)
-	SIGUSR2 = Integer(0x1f)
+	SIGUSR1  = Integer(0x1e)
+	SIGUSR2  = Integer(0x1f)
+	SIGWINCH = Integer(0x1c)
 
 	LOCK_EX = int(0x2)
 	LOCK_NB = int(0x4)
+
+	// detachedProcess the child does not inherit the console
+	detachedProcess = 0x00000008
 )
 
 func Flock(fd int, how int) error {
 	return nil
 }
+
+// probe check that a process with pid exists, syscall.ESRCH when it does not
+func probe(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return syscall.ESRCH
+	}
+	return process.Release()
+}
+
+// alive whether a process with pid exists
+func alive(pid int) bool {
+	return probe(pid) == nil
+}
+
+// groupAlive process groups are not tracked on Windows
+func groupAlive(pid int) bool {
+	return false
+}
+
+// signalGroup process groups are not tracked on Windows
+func signalGroup(pid int, sig syscall.Signal) error {
+	return nil
+}
+
+// leadGroup process groups are not tracked on Windows
+func leadGroup() error {
+	return nil
+}
+
+// reexec Windows cannot replace the running program
+func reexec() error {
+	return syscall.EWINDOWS
+}
+
+// setsid start cmd detached from the console, in a new process group
+func setsid(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= detachedProcess | syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// closeInheritedFiles Windows only passes inheritable handles, which Go does not create
+func closeInheritedFiles() {}
+
+// umask Windows has no umask
+func umask(mask int) {}
+
+// setCredentials Windows services choose their account in the Service Control Manager
+func setCredentials(uid, gid int, groups []int) error {
+	return syscall.EWINDOWS
+}
+
+// chroot Windows has no chroot
+func chroot(path string) error {
+	return ErrUnsupported
+}
+
+// unlinkat not reached without chroot
+func unlinkat(dir *os.File, name string) error {
+	return syscall.EWINDOWS
+}
+
+// dup2 the standard handles of a Windows process are not replaced
+func dup2(oldfd, newfd int) error {
+	return nil
+}
@@ -0,0 +1,143 @@
+package daemon
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Facility the syslog facility of the messages of SyslogWriter
+type Facility int
+
+const (
+	// FacilityUser user-level messages
+	FacilityUser Facility = 1 << 3
+	// FacilityDaemon system daemons
+	FacilityDaemon Facility = 3 << 3
+	// FacilityLocal0 local use 0, up to FacilityLocal7
+	FacilityLocal0 Facility = 16 << 3
+	// FacilityLocal1 local use 1
+	FacilityLocal1 Facility = 17 << 3
+	// FacilityLocal2 local use 2
+	FacilityLocal2 Facility = 18 << 3
+	// FacilityLocal3 local use 3
+	FacilityLocal3 Facility = 19 << 3
+	// FacilityLocal4 local use 4
+	FacilityLocal4 Facility = 20 << 3
+	// FacilityLocal5 local use 5
+	FacilityLocal5 Facility = 21 << 3
+	// FacilityLocal6 local use 6
+	FacilityLocal6 Facility = 22 << 3
+	// FacilityLocal7 local use 7
+	FacilityLocal7 Facility = 23 << 3
+)
+
+const (
+	// priorityErr the severity of the standard error lines
+	priorityErr = 3
+	// priorityInfo the severity of the standard output lines
+	priorityInfo = 6
+)
+
+var (
+	// syslogSockets where the local syslog daemon listens, depending on the system
+	syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+	// journaldSocket the native protocol socket of systemd-journald
+	journaldSocket = "/run/systemd/journal/socket"
+)
+
+// SyslogWriter the standard output and error of the worker as messages of the local syslog daemon, one per line.
+// the output has the info severity and the error the err severity, such as proc.SetOutput(daemon.SyslogWriter("http", daemon.FacilityDaemon))
+func SyslogWriter(tag string, facility Facility) (stdout, stderr io.Writer) {
+	logger := &systemLogger{sockets: syslogSockets}
+	format := func(priority int, line []byte) []byte {
+		return []byte(fmt.Sprintf("<%d>%s %s[%d]: %s\n", int(facility)|priority, time.Now().Format(time.Stamp), tag, os.Getpid(), line))
+	}
+	return logger.writer(priorityInfo, format), logger.writer(priorityErr, format)
+}
+
+// JournaldWriter the standard output and error of the worker as entries of systemd-journald, one per line,
+// with the info and err priorities. the identifier is the name of the binary
+func JournaldWriter() (stdout, stderr io.Writer) {
+	logger := &systemLogger{sockets: []string{journaldSocket}}
+	identifier := filepath.Base(executable())
+	format := func(priority int, line []byte) []byte {
+		return []byte(fmt.Sprintf("PRIORITY=%d\nSYSLOG_IDENTIFIER=%s\nMESSAGE=%s\n", priority, identifier, line))
+	}
+	return logger.writer(priorityInfo, format), logger.writer(priorityErr, format)
+}
+
+// systemLogger a datagram connection to the system logger, shared by the output and the error
+type systemLogger struct {
+	mutex   sync.Mutex
+	sockets []string // tried in order
+	conn    net.Conn
+}
+
+// send send a message, connecting again once when the logger went away
+func (logger *systemLogger) send(message []byte) error {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	for attempt := 0; attempt < 2; attempt++ {
+		if logger.conn == nil {
+			if err := logger.connect(); err != nil {
+				return err
+			}
+		}
+		if _, err := logger.conn.Write(message); err == nil {
+			return nil
+		}
+		_ = logger.conn.Close()
+		logger.conn = nil
+	}
+	return errors.New("system logger unavailable")
+}
+
+// connect connect to the first socket that accepts
+func (logger *systemLogger) connect() error {
+	var err error
+	for _, socket := range logger.sockets {
+		if logger.conn, err = net.Dial("unixgram", socket); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// writer a writer sending every line to the logger with priority
+func (logger *systemLogger) writer(priority int, format func(priority int, line []byte) []byte) io.Writer {
+	return &lineWriter{emit: func(line []byte) {
+		// lines are dropped while the logger is unavailable, the worker must not block on its output
+		if len(line) > 0 {
+			_ = logger.send(format(priority, line))
+		}
+	}}
+}
+
+// lineWriter call emit for every complete line written, without its newline
+type lineWriter struct {
+	mutex   sync.Mutex
+	pending []byte
+	emit    func(line []byte)
+}
+
+func (writer *lineWriter) Write(p []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	writer.pending = append(writer.pending, p...)
+	for {
+		end := bytes.IndexByte(writer.pending, '\n')
+		if end < 0 {
+			return len(p), nil
+		}
+		line := writer.pending[:end]
+		writer.pending = writer.pending[end+1:]
+		writer.emit(line)
+	}
+}
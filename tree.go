@@ -0,0 +1,67 @@
+package daemon
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// stopWorker stop worker and wait up to timeout for it to exit, reporting the result on the terminal
+func stopWorker(cmd *cobra.Command, worker *Process, path string, timeout time.Duration) bool {
+	result := worker.result(StopCommand, StateNotRunning)
+	pid, err := worker.pid.Read()
+	if err != nil || !alive(pid) {
+		report(cmd, result, "%s: not running\n", path)
+		return true
+	}
+
+	started := time.Now()
+	result.Pid = pid
+	if err = signalPid(pid, worker.stopSignal); err != nil {
+		result.State, result.Error = StateFailed, err.Error()
+		report(cmd, result, "%s: %v\n", path, err)
+		return false
+	}
+	if !waitExit(pid, timeout) {
+		result.State, result.Error = StateRunning, fmt.Sprintf("still running after %s", timeout)
+		report(cmd, result, "%s: still running after %s\n", path, timeout)
+		return false
+	}
+	result.State = StateStopped
+	report(cmd, result, "%s: stopped in %s\n", path, time.Since(started).Round(time.Millisecond))
+	return true
+}
+
+// stopChildren stop the workers below daemon, deepest first and siblings in reverse registration order,
+// waiting for each one before proceeding. returns false if any of them did not stop within timeout
+func (daemon *Daemon) stopChildren(cmd *cobra.Command, timeout time.Duration) bool {
+	ok := true
+	for i := len(daemon.order) - 1; i >= 0; i-- {
+		child := daemon.order[i]
+		if !child.stopChildren(cmd, timeout) {
+			ok = false
+		}
+		if child.worker != nil && !stopWorker(cmd, child.worker, child.command.CommandPath(), timeout) {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// stopsChildren let the stop command of daemon stop the child workers first with --children
+func (daemon *Daemon) stopsChildren(stop *cobra.Command) {
+	run := stop.RunE
+	stop.RunE = runE(func(cmd *cobra.Command, args []string) error {
+		if children, _ := cmd.Flags().GetBool("children"); children && !dryRun(cmd) {
+			timeout, _ := cmd.Flags().GetDuration("children-timeout")
+			if !daemon.stopChildren(cmd, timeout) {
+				err := fmt.Errorf("not every child of %s stopped, leaving it running", daemon.command.CommandPath())
+				return failed(daemon.worker.result(StopCommand, StateRunning), err, 1)
+			}
+		}
+		return run(cmd, args)
+	})
+	stop.Flags().Bool("children", false, "stop the child workers first, in reverse registration order")
+	stop.Flags().Duration("children-timeout", DefaultStopTimeout+5*time.Second, "how long to wait for each child worker")
+}
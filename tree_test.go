@@ -0,0 +1,76 @@
+package daemon
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TestStopChildren(t *testing.T) {
+	sleep := lookSleep(t)
+
+	tests := []struct {
+		name     string
+		stubborn bool // a child ignores the stop signal
+		running  []string
+	}{
+		{"stopped", false, nil},
+		{"child still running", true, []string{"parent", "stubborn"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "tree")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			root := &Daemon{command: &cobra.Command{Use: "app"}}
+			parent := root.AddWorker(NewProcess(testWorker{dir: dir, name: "parent"}))
+			parent.AddWorker(NewProcess(testWorker{dir: dir, name: "child"})).
+				AddWorker(NewProcess(testWorker{dir: dir, name: "grandchild"}))
+			processes := map[string]*exec.Cmd{
+				"parent":     runPid(t, dir, "parent", sleep, "30"),
+				"child":      runPid(t, dir, "child", sleep, "30"),
+				"grandchild": runPid(t, dir, "grandchild", sleep, "30"),
+			}
+			if test.stubborn {
+				parent.AddWorker(NewProcess(testWorker{dir: dir, name: "stubborn"}))
+				// the ignored stop signal, SIGUSR1, is kept by exec
+				ready := filepath.Join(dir, "ready")
+				processes["stubborn"] = runPid(t, dir, "stubborn", "/bin/sh", "-c", `trap "" USR1; : > `+ready+"; exec "+sleep+" 30")
+				for !exists(ready) {
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+			defer func() {
+				for _, process := range processes {
+					_ = process.Process.Kill()
+				}
+			}()
+
+			root.command.SetArgs([]string{"parent", "stop", "--children", "--children-timeout", "500ms"})
+			root.command.SetOutput(ioutil.Discard)
+			err = root.command.Execute()
+			var failure *CommandError
+			if stubborn := errors.As(err, &failure) && failure.Code == 1; stubborn != test.stubborn || !stubborn && err != nil {
+				t.Fatalf("stop --children = %v", err)
+			}
+			running := make(map[string]bool)
+			for _, name := range test.running {
+				running[name] = true
+			}
+			for name, process := range processes {
+				if alive(process.Process.Pid) != running[name] {
+					t.Errorf("%s running: %v, want %v", name, !running[name], running[name])
+				}
+			}
+		})
+	}
+}
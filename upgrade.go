@@ -0,0 +1,159 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// SetUpgradeSignal upgrade the worker on sig instead of SIGWINCH, see SetStopSignal
+func (process *Process) SetUpgradeSignal(sig os.Signal) *Process {
+	return process.configure("SetUpgradeSignal", func() {
+		process.signals.removeDefaults(process.upgradeSignal)
+		process.upgradeSignal = sig
+		process.registerDefaultUpgradeHandle()
+	})
+}
+
+// register the default upgrade method and listen for the upgrade signal, WINCH by default
+func (process *Process) registerDefaultUpgradeHandle() {
+	process.onDefault(process.upgradeSignal, func() {
+		if process.foreground {
+			// the binary executed again in this process is already the one on disk
+			process.restartInPlace()
+			return
+		}
+		if atomic.LoadInt32(&process.terminating) != 0 {
+			return
+		}
+		if err := process.upgrade(); err != nil {
+			process.error("upgrade failed, the running worker is kept", "err", err)
+			return
+		}
+		if err := process.within("restart", process.drain); err != nil {
+			process.error("restart failed", "err", err)
+		}
+		process.hookExit(0)
+		os.Exit(0)
+	})
+}
+
+// upgrade start the binary on disk, which a deployment may have replaced, with the listeners of this process, and
+// wait until it reported it started. on failure this process takes its pid file back and keeps running
+func (process *Process) upgrade() error {
+	process.info("upgrade triggered", "executable", executable())
+	process.emit(OnRestart, "")
+	process.hookPreRestart()
+	if err := process.saveState(); err != nil {
+		process.error("save state failed", "err", err)
+	}
+	process.removePid()
+	process.closeControl()
+
+	restarts := os.Getenv(process.restartsEnv())
+	_ = os.Unsetenv(process.daemonTag)
+	_ = os.Setenv(process.restartsEnv(), strconv.Itoa(process.restarts()+1))
+	_ = os.Setenv(process.restartReasonEnv(), "upgrade")
+	wait := process.startWait
+	process.startWait = DefaultReadyWait
+	err := process.Run()
+	if err == nil {
+		err = process.waitStartup(process.startWait)
+	}
+	if err == nil {
+		return nil
+	}
+
+	if process.spawned != 0 && process.spawned != os.Getpid() {
+		_ = signalPid(process.spawned, os.Kill)
+	}
+	process.startWait = wait
+	_ = os.Setenv(process.daemonTag, "true")
+	_ = os.Setenv(process.restartsEnv(), restarts)
+	if err := process.pid.Save(); err != nil {
+		process.error("save pid failed", "err", err)
+	}
+	process.writeStatus()
+	if err := process.serveControl(); err != nil {
+		process.error("control socket failed", "err", err)
+	}
+	if err := process.serveBus(); err != nil {
+		process.error("bus socket failed", "err", err)
+	}
+	return err
+}
+
+func upgrade(worker *Process) *cobra.Command {
+	upgrade := &cobra.Command{
+		Use:   "upgrade",
+		Short: fmt.Sprintf("start the binary of %s on disk and stop the running one once the new one started", worker.worker.Name()),
+		RunE: runE(func(cmd *cobra.Command, args []string) error {
+			state, err := worker.triggerUpgrade(cmd)
+			if err != nil {
+				return failed(worker.result(UpgradeCommand, ""), err, 1)
+			}
+			if state == StateUpgraded {
+				report(cmd, worker.result(UpgradeCommand, state), "%s upgraded\n", worker.worker.Name())
+			} else {
+				report(cmd, worker.result(UpgradeCommand, state), "")
+			}
+			return nil
+		}),
+	}
+
+	addWaitFlag(upgrade)
+	return upgrade
+}
+
+// triggerUpgrade signal the running worker to upgrade and wait until it did, unless --wait of cmd is negative.
+// returns the state of the worker, StateUpgraded or "upgrading" without waiting
+func (process *Process) triggerUpgrade(cmd *cobra.Command) (string, error) {
+	pid, err := process.pid.load(process.pid.SaveFilename())
+	if os.IsNotExist(err) {
+		err = fmt.Errorf("%s is %w", process.worker.Name(), ErrNotRunning)
+	}
+	var previous time.Time
+	if err == nil {
+		previous, err = process.pid.saved()
+	}
+	if err == nil {
+		err = signalPid(pid, process.upgradeSignal)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	wait := process.waitFlag(cmd)
+	if wait < 0 {
+		return "upgrading", nil
+	}
+	if err = process.waitUpgraded(pid, previous, DefaultReadyWait+wait); err != nil {
+		return "", err
+	}
+	return StateUpgraded, nil
+}
+
+// waitUpgraded wait until the process pid, which saved the pid file at previous, exited after another one saved it.
+// it fails when pid saved the pid file again, it kept running because the new process did not start
+func (process *Process) waitUpgraded(pid int, previous time.Time, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if saved, err := process.pid.saved(); err == nil && saved.After(previous) {
+			current, err := process.pid.Read()
+			switch {
+			case err == nil && current == pid:
+				return fmt.Errorf("%s did not start, pid %d keeps running, see its error output", executable(), pid)
+			case err == nil && alive(current) && !alive(pid):
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s did not upgrade, %w after %s", process.worker.Name(), ErrTimeout, timeout)
+		}
+		time.Sleep(waitInterval)
+	}
+}
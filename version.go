@@ -0,0 +1,74 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// VersionInfo what the binary is, printed by the version command
+type VersionInfo struct {
+	Version  string `json:"version,omitempty"` // set by SetVersion
+	Commit   string `json:"commit,omitempty"`
+	Date     string `json:"date,omitempty"`
+	Go       string `json:"go"`
+	Module   string `json:"module,omitempty"` // path and version of the main module, from the build info
+	Platform string `json:"platform"`
+}
+
+var versionInfo VersionInfo
+
+// SetVersion set the version, commit and build date of the binary, usually injected with
+// -ldflags "-X main.version=...", printed by the version command and recorded in the status file
+func SetVersion(version, commit, date string) {
+	versionInfo.Version, versionInfo.Commit, versionInfo.Date = version, commit, date
+}
+
+// Version the version of the binary
+func Version() VersionInfo {
+	info := versionInfo
+	info.Go, info.Platform = runtime.Version(), runtime.GOOS+"/"+runtime.GOARCH
+	if build, ok := debug.ReadBuildInfo(); ok {
+		info.Module = build.Main.Path + "@" + build.Main.Version
+		if info.Version == "" {
+			info.Version = build.Main.Version
+		}
+	}
+	return info
+}
+
+// addVersionCommand add the version command to the root, unless the program has its own
+func (daemon *Daemon) addVersionCommand() {
+	for _, cmd := range daemon.command.Commands() {
+		if cmd.Name() == "version" {
+			return
+		}
+	}
+	daemon.command.AddCommand(&cobra.Command{
+		Use:   "version",
+		Short: fmt.Sprintf("print the version of %s", Name()),
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			info := Version()
+			if jsonOutput(cmd) {
+				_ = json.NewEncoder(os.Stdout).Encode(info)
+				return
+			}
+			fmt.Printf("%s %s\n", Name(), info.Version)
+			if info.Commit != "" {
+				fmt.Printf("  commit:   %s\n", info.Commit)
+			}
+			if info.Date != "" {
+				fmt.Printf("  built:    %s\n", info.Date)
+			}
+			fmt.Printf("  go:       %s %s\n", info.Go, info.Platform)
+			if info.Module != "" {
+				fmt.Printf("  module:   %s\n", info.Module)
+			}
+		},
+	})
+}
@@ -0,0 +1,68 @@
+package daemon
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// waitInterval how often waitExit checks the process
+	waitInterval = 100 * time.Millisecond
+	// waitGrace added to the stop timeout, the time the process needs besides worker.Stop
+	waitGrace = 5 * time.Second
+	// forever the wait of a process without stop timeout
+	forever = time.Duration(1 << 62)
+)
+
+// waitExit wait until the process pid is gone, returns false if it is still alive after timeout
+func waitExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for alive(pid) {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(waitInterval)
+	}
+	return true
+}
+
+// waitRestarted wait until the process that saved the pid file at previous has been replaced by one that saved it again.
+// in the foreground the binary is executed again by the same process, so the pid may stay the same
+func (process *Process) waitRestarted(previous time.Time, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if saved, err := process.pid.saved(); err == nil && saved.After(previous) {
+			if pid, err := process.pid.Read(); err == nil && alive(pid) {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s did not restart, %w after %s", process.worker.Name(), ErrTimeout, timeout)
+		}
+		time.Sleep(waitInterval)
+	}
+}
+
+// stopWait how long the commands wait for the process by default, the stop timeout plus a grace period
+func (process *Process) stopWait() time.Duration {
+	if process.stopTimeout <= 0 {
+		return forever
+	}
+	return process.stopTimeout + waitGrace
+}
+
+// addWaitFlag add --wait to a command that waits for the process
+func addWaitFlag(cmd *cobra.Command) {
+	cmd.Flags().Duration("wait", 0, "how long to wait for the process, defaults to the stop timeout plus 5s, negative returns immediately")
+}
+
+// waitFlag the value of --wait, negative when the command should not wait
+func (process *Process) waitFlag(cmd *cobra.Command) time.Duration {
+	wait, _ := cmd.Flags().GetDuration("wait")
+	if wait == 0 {
+		return process.stopWait()
+	}
+	return wait
+}
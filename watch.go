@@ -0,0 +1,98 @@
+package daemon
+
+import (
+	"crypto/sha256"
+	"io"
+	"os"
+	"time"
+)
+
+// watchInterval how often the files of EnableWatch are checked
+const watchInterval = 2 * time.Second
+
+// fileStamp what tells a watched file changed, the hash is only computed once the size or time changed
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	sum     []byte
+}
+
+// EnableWatch restart the worker through the restart handler when its binary or one of paths, such as config files,
+// changes, for deployments that copy a new binary over the old one. a file is compared by size, time and hash once it
+// stopped changing for one check, so a copy in progress or a touch do not restart it
+func (process *Process) EnableWatch(paths ...string) *Process {
+	return process.configure("EnableWatch", func() {
+		if process.watched == nil {
+			process.watched = []string{executable()}
+		}
+		for _, path := range paths {
+			process.watched = append(process.watched, absolute(path))
+		}
+	})
+}
+
+// watchFiles in the child, poll the files of EnableWatch and restart the worker once one of them changed
+func (process *Process) watchFiles() {
+	if len(process.watched) == 0 {
+		return
+	}
+	stamps := make(map[string]fileStamp)
+	for _, path := range process.watched {
+		stamps[path], _ = stampFile(path, nil)
+	}
+
+	pending := make(map[string]os.FileInfo) // the files that changed, until they stop changing
+	for range time.Tick(watchInterval) {
+		for _, path := range process.watched {
+			info, err := os.Stat(path)
+			if err != nil {
+				// a file being replaced may be missing for a moment
+				delete(pending, path)
+				continue
+			}
+			stamp := stamps[path]
+			if info.ModTime().Equal(stamp.modTime) && info.Size() == stamp.size {
+				delete(pending, path)
+				continue
+			}
+			if previous, ok := pending[path]; !ok || !info.ModTime().Equal(previous.ModTime()) || info.Size() != previous.Size() {
+				pending[path] = info
+				continue
+			}
+
+			delete(pending, path)
+			current, err := stampFile(path, info)
+			if err != nil {
+				continue
+			}
+			stamps[path] = current
+			if string(current.sum) == string(stamp.sum) {
+				continue
+			}
+			process.info("watched file changed, restarting", "file", path)
+			if err = process.requestRestart(path + " changed"); err != nil {
+				process.error("restart failed", "err", err)
+			}
+			return
+		}
+	}
+}
+
+// stampFile the stamp of the file path, info is its stat if known
+func stampFile(path string, info os.FileInfo) (fileStamp, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	defer file.Close()
+	if info == nil {
+		if info, err = file.Stat(); err != nil {
+			return fileStamp{}, err
+		}
+	}
+	hash := sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size(), sum: hash.Sum(nil)}, nil
+}
@@ -0,0 +1,119 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// webhookTimeout how long posting to a webhook may take
+const webhookTimeout = 5 * time.Second
+
+// eventTexts how the events read in the text of a WebhookPayload
+var eventTexts = map[ScriptEvent]string{
+	OnStart:   "started",
+	OnStop:    "stopped",
+	OnCrash:   "crashed",
+	OnRestart: "restarting",
+	OnGiveUp:  "keeps exiting, no longer restarted",
+}
+
+// webhook a URL the events are posted to
+type webhook struct {
+	url    string
+	events []ScriptEvent // every event when empty
+}
+
+// WebhookPayload the JSON document posted to the webhooks of SetWebhook
+type WebhookPayload struct {
+	Event    ScriptEvent `json:"event"`
+	Worker   string      `json:"worker"`
+	Host     string      `json:"host"`
+	Pid      int         `json:"pid"`
+	Time     time.Time   `json:"time"`
+	Restarts int         `json:"restarts"` // restarts of the worker since the start command, a crash loop shows here
+	Crash    string      `json:"crash,omitempty"`
+	Text     string      `json:"text"` // a summary, so that chat webhooks such as Slack's show it as is
+}
+
+// SetWebhook POST a WebhookPayload to url when one of events happens in the child, every event when none is given,
+// so that a crash pages someone. like the scripts of SetScript, it runs on start, stop, restart and crash
+func (process *Process) SetWebhook(url string, events ...ScriptEvent) *Process {
+	return process.configure("SetWebhook", func() {
+		process.webhooks = append(process.webhooks, webhook{url: url, events: events})
+	})
+}
+
+// wants whether event is posted to hook
+func (hook webhook) wants(event ScriptEvent) bool {
+	if len(hook.events) == 0 {
+		return true
+	}
+	for _, wanted := range hook.events {
+		if wanted == event {
+			return true
+		}
+	}
+	return false
+}
+
+// emit run the script and post the webhooks of event, crash is the panic of an OnCrash event
+func (process *Process) emit(event ScriptEvent, crash string) {
+	if crash != "" {
+		process.runScript(event, fmt.Sprintf("DAEMON_CRASH=%s", crash))
+	} else {
+		process.runScript(event)
+	}
+	process.postWebhooks(event, crash)
+}
+
+// postWebhooks post event to the webhooks that want it, one after the other, failures are logged
+func (process *Process) postWebhooks(event ScriptEvent, crash string) {
+	var payload []byte
+	for _, hook := range process.webhooks {
+		if !hook.wants(event) {
+			continue
+		}
+		if payload == nil {
+			payload, _ = json.Marshal(process.webhookPayload(event, crash))
+		}
+		if err := postWebhook(hook.url, payload); err != nil {
+			process.error("webhook failed", "event", event, "url", hook.url, "err", err)
+		}
+	}
+}
+
+// webhookPayload describe event
+func (process *Process) webhookPayload(event ScriptEvent, crash string) WebhookPayload {
+	host, _ := os.Hostname()
+	payload := WebhookPayload{
+		Event:    event,
+		Worker:   process.worker.Name(),
+		Host:     host,
+		Pid:      os.Getpid(),
+		Time:     time.Now(),
+		Restarts: process.restarts(),
+		Crash:    crash,
+	}
+	payload.Text = fmt.Sprintf("%s on %s %s", payload.Worker, host, eventTexts[event])
+	if crash != "" {
+		payload.Text += ": " + crash
+	}
+	return payload
+}
+
+// postWebhook post payload to url, a status other than 2xx is an error
+func postWebhook(url string, payload []byte) error {
+	response, err := (&http.Client{Timeout: webhookTimeout}).Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	_ = response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%s", response.Status)
+	}
+	return nil
+}
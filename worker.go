@@ -0,0 +1,128 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// WorkerV2 a context-aware worker, the context of Start is cancelled on stop signals and a startup error is reported.
+// the optional interfaces (Command, Validator...) work the same as with Worker
+type WorkerV2 interface {
+	// PidSavePath pid file save path
+	PidSavePath() string
+	// Name pid file name
+	Name() string
+	// Start Program startup entry method, return when ctx is cancelled
+	Start(ctx context.Context) error
+	// Stop Program stop handle, ctx expires with the stop timeout
+	Stop(ctx context.Context) error
+}
+
+// contextWorker adapt a WorkerV2 to the Worker the process runs
+type contextWorker struct {
+	WorkerV2
+	process *Process
+
+	mutex    sync.Mutex
+	cancel   context.CancelFunc
+	err      error
+	returned bool // Start returned nil before its context was cancelled, the work is done
+}
+
+// NewProcessV2 create a process instance with WorkerV2
+func NewProcessV2(worker WorkerV2) *Process {
+	adapter := &contextWorker{WorkerV2: worker}
+	process := NewProcess(adapter)
+	process.impl = worker
+	adapter.process = process
+	return process
+}
+
+// Start run the worker until its context is cancelled, an error returned before that is kept for startError
+func (worker *contextWorker) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	worker.mutex.Lock()
+	worker.cancel = cancel
+	worker.returned = false
+	worker.mutex.Unlock()
+
+	err := worker.WorkerV2.Start(ctx)
+	worker.mutex.Lock()
+	defer worker.mutex.Unlock()
+	if ctx.Err() == nil {
+		worker.err, worker.returned = err, err == nil
+	}
+}
+
+// Stop cancel the context of Start and stop the worker within the stop timeout
+func (worker *contextWorker) Stop() error {
+	worker.mutex.Lock()
+	if worker.cancel != nil {
+		worker.cancel()
+	}
+	worker.mutex.Unlock()
+
+	ctx := context.Background()
+	if worker.process.stopTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, worker.process.stopTimeout)
+		defer cancel()
+	}
+	return worker.WorkerV2.Stop(ctx)
+}
+
+// Restart stop the worker and run Start again with a fresh context
+func (worker *contextWorker) Restart() error {
+	atomic.AddInt32(&worker.process.generation, 1)
+	if err := worker.Stop(); err != nil {
+		return err
+	}
+	go worker.process.run()
+	return nil
+}
+
+// finished whether Start returned nil before its context was cancelled
+func (worker *contextWorker) finished() bool {
+	worker.mutex.Lock()
+	defer worker.mutex.Unlock()
+	return worker.returned
+}
+
+// startError the error Start returned, nil if it is still running or was stopped
+func (worker *contextWorker) startError() error {
+	worker.mutex.Lock()
+	defer worker.mutex.Unlock()
+	return worker.err
+}
+
+// drain stop the worker of this process, which a new process runs from now on: a Worker drains in Restart,
+// a WorkerV2 is stopped
+func (process *Process) drain() error {
+	if worker, ok := process.worker.(*contextWorker); ok {
+		return worker.Stop()
+	}
+	return process.worker.Restart()
+}
+
+// returned whether the worker is a WorkerV2 whose Start returned nil without being stopped, the child exits then
+func (process *Process) returned() bool {
+	worker, ok := process.worker.(*contextWorker)
+	return ok && worker.finished()
+}
+
+// startFailed report the startup error of a WorkerV2 and exit, so that a supervisor sees the failure
+func (process *Process) startFailed() {
+	worker, ok := process.worker.(*contextWorker)
+	if !ok {
+		return
+	}
+	if err := worker.startError(); err != nil {
+		process.error("start failed", "err", err)
+		process.reportStartup(err)
+		process.removePid()
+		process.hookExit(1)
+		os.Exit(1)
+	}
+}